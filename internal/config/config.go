@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+	_ "time/tzdata" // 系统缺少时区数据库时仍能校验 quota_timezones
 
+	"github.com/k12/submit-sitemap/internal/submitter"
 	"github.com/k12/submit-sitemap/pkg/types"
 	"gopkg.in/yaml.v3"
 )
@@ -70,6 +74,11 @@ func LoadAll(configDir string) (*types.Config, error) {
 		config.Settings = *globalSettings
 	}
 
+	// 将旧的 quotas/api 写法映射为 engines，之后的验证只检查 engines
+	if err := normalize(config); err != nil {
+		return nil, fmt.Errorf("配置验证失败: %w", err)
+	}
+
 	// 验证配置
 	if err := validate(config); err != nil {
 		return nil, fmt.Errorf("配置验证失败: %w", err)
@@ -95,9 +104,27 @@ func loadSiteConfigFile(configPath string) (*types.SiteConfigFile, error) {
 		return nil, fmt.Errorf("解析YAML失败: %w", err)
 	}
 
+	resolveSourceFiles(&siteConfig.SiteConfig, configPath)
+
 	return &siteConfig, nil
 }
 
+// resolveSourceFiles 本地来源（含引擎专用来源）的相对路径基于配置文件所在目录
+func resolveSourceFiles(site *types.SiteConfig, configPath string) {
+	resolve := func(sources []types.SourceConfig) {
+		for i, source := range sources {
+			if source.File != "" && !filepath.IsAbs(source.File) {
+				sources[i].File = filepath.Join(filepath.Dir(configPath), source.File)
+			}
+		}
+	}
+
+	resolve(site.Sources)
+	for _, engine := range site.Engines {
+		resolve(engine.Sources)
+	}
+}
+
 // Load 加载单个配置文件（保留向后兼容性）
 func Load(configPath string) (*types.Config, error) {
 	// 检查文件是否存在
@@ -117,6 +144,15 @@ func Load(configPath string) (*types.Config, error) {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
 
+	for i := range config.Sites {
+		resolveSourceFiles(&config.Sites[i], configPath)
+	}
+
+	// 将旧的 quotas/api 写法映射为 engines，之后的验证只检查 engines
+	if err := normalize(&config); err != nil {
+		return nil, fmt.Errorf("配置验证失败: %w", err)
+	}
+
 	// 验证配置
 	if err := validate(&config); err != nil {
 		return nil, fmt.Errorf("配置验证失败: %w", err)
@@ -128,22 +164,70 @@ func Load(configPath string) (*types.Config, error) {
 	return &config, nil
 }
 
-// validate 验证配置
+// normalize 将各站点旧的 quotas/api 配置映射为 engines，在验证之前调用，验证本身不修改配置
+func normalize(config *types.Config) error {
+	for i := range config.Sites {
+		site := &config.Sites[i]
+		if err := applyLegacyEngines(site); err != nil {
+			return fmt.Errorf("网站 #%d (%s): %w", i+1, site.Domain, err)
+		}
+	}
+	return nil
+}
+
+// validate 验证配置，不修改配置
 func validate(config *types.Config) error {
 	if len(config.Sites) == 0 {
 		return fmt.Errorf("至少需要配置一个网站")
 	}
 
+	for platform, limit := range config.Settings.RateLimits {
+		if limit.RequestsPerSecond < 0 || limit.URLsPerMinute < 0 {
+			return fmt.Errorf("rate_limits.%s: 限速不能为负数", platform)
+		}
+	}
+
+	for platform, name := range config.Settings.QuotaTimezones {
+		if _, err := time.LoadLocation(name); err != nil {
+			return fmt.Errorf("quota_timezones.%s: 无效的时区 %q: %w", platform, name, err)
+		}
+	}
+
 	for i, site := range config.Sites {
 		if site.Domain == "" {
 			return fmt.Errorf("网站 #%d: domain 不能为空", i+1)
 		}
-		if site.SitemapURL == "" {
-			return fmt.Errorf("网站 #%d (%s): sitemap_url 不能为空", i+1, site.Domain)
+		if site.SitemapURL == "" && len(site.Sources) == 0 && !site.DiscoverSitemaps {
+			return fmt.Errorf("网站 #%d (%s): sitemap_url、sources 和 discover_sitemaps 至少需要配置一个", i+1, site.Domain)
+		}
+		for j, source := range site.Sources {
+			if err := validateSource(source); err != nil {
+				return fmt.Errorf("网站 #%d (%s): sources #%d: %w", i+1, site.Domain, j+1, err)
+			}
 		}
 
-		// 检查至少配置了一个平台
-		hasQuota := site.Quotas.Baidu > 0 || site.Quotas.Bing > 0 || site.Quotas.Google > 0
+		// 检查至少配置了一个平台，且引擎名都已注册
+		hasQuota := false
+		for name, engine := range site.Engines {
+			if _, ok := submitter.Lookup(name); !ok {
+				return fmt.Errorf("网站 #%d (%s): 未知的提交引擎 %q（可用: %v）", i+1, site.Domain, name, submitter.Engines())
+			}
+			if engine.Quota < 0 {
+				return fmt.Errorf("网站 #%d (%s): engines.%s.quota 不能为负数", i+1, site.Domain, name)
+			}
+			if engine.Quota > 0 {
+				hasQuota = true
+			}
+			for j, source := range engine.Sources {
+				if err := validateSource(source); err != nil {
+					return fmt.Errorf("网站 #%d (%s): engines.%s.sources #%d: %w", i+1, site.Domain, name, j+1, err)
+				}
+			}
+			// 删除推送不能使用站点的sitemap，否则会把在线页面提交删除
+			if name == "baidu_del" && engine.Quota > 0 && len(engine.Sources) == 0 {
+				return fmt.Errorf("网站 #%d (%s): engines.baidu_del 必须配置 sources（已失效的URL列表）", i+1, site.Domain)
+			}
+		}
 		if !hasQuota {
 			return fmt.Errorf("网站 #%d (%s): 至少需要配置一个平台的配额", i+1, site.Domain)
 		}
@@ -152,11 +236,103 @@ func validate(config *types.Config) error {
 	return nil
 }
 
+// applyLegacyEngines 将旧的 quotas/api 配置映射为 engines，engines 中已配置的同名引擎优先
+// bing、naver 未配置的 host 与直接写在 engines 下的引擎一样，在创建提交器时补全为站点域名
+func applyLegacyEngines(site *types.SiteConfig) error {
+	legacy := []struct {
+		name   string
+		quota  int
+		config any
+	}{
+		{"baidu", site.Quotas.Baidu, site.API.Baidu},
+		{"bing", site.Quotas.Bing, site.API.Bing},
+		{"google", site.Quotas.Google, site.API.Google},
+		{"yandex", site.Quotas.Yandex, site.API.Yandex},
+		{"naver", site.Quotas.Naver, site.API.Naver},
+	}
+
+	for _, l := range legacy {
+		if l.quota <= 0 {
+			continue
+		}
+		if _, ok := site.Engines[l.name]; ok {
+			continue
+		}
+		engine, err := types.NewEngineConfig(l.quota, l.config)
+		if err != nil {
+			return fmt.Errorf("转换 %s 配置失败: %w", l.name, err)
+		}
+		if site.Engines == nil {
+			site.Engines = make(map[string]types.EngineConfig)
+		}
+		site.Engines[l.name] = engine
+	}
+
+	return applyBaiduDaily(site)
+}
+
+// applyBaiduDaily 百度配置了 daily_quota 时启用快速收录引擎，engines 中已配置 baidu_daily 时以其为准
+func applyBaiduDaily(site *types.SiteConfig) error {
+	baidu, ok := site.Engines["baidu"]
+	if !ok {
+		return nil
+	}
+	if _, ok := site.Engines["baidu_daily"]; ok {
+		return nil
+	}
+
+	var config types.BaiduConfig
+	if err := baidu.Decode(&config); err != nil {
+		return fmt.Errorf("解析 baidu 配置失败: %w", err)
+	}
+	if config.DailyQuota < 0 {
+		return fmt.Errorf("baidu.daily_quota 不能为负数")
+	}
+	if config.DailyQuota == 0 {
+		return nil
+	}
+
+	engine, err := types.NewEngineConfig(config.DailyQuota, config)
+	if err != nil {
+		return fmt.Errorf("转换 baidu_daily 配置失败: %w", err)
+	}
+	site.Engines["baidu_daily"] = engine
+	return nil
+}
+
+// validateSource 验证URL来源配置
+func validateSource(source types.SourceConfig) error {
+	if (source.URL == "") == (source.File == "") {
+		return fmt.Errorf("url 和 file 必须且只能配置一个")
+	}
+	for _, pattern := range append(append([]string(nil), source.Include...), source.Exclude...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("无效的正则表达式 %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// defaultRateLimits 各平台默认限速
+var defaultRateLimits = map[string]types.RateLimit{
+	"baidu":        {RequestsPerSecond: 1},
+	"baidu_daily":  {RequestsPerSecond: 1},
+	"baidu_update": {RequestsPerSecond: 1},
+	"baidu_del":    {RequestsPerSecond: 1},
+	"bing":         {RequestsPerSecond: 5},
+	"google":       {RequestsPerSecond: 2},
+	"yandex":       {RequestsPerSecond: 1},
+	"naver":        {RequestsPerSecond: 5},
+}
+
 // setDefaults 设置默认值
 func setDefaults(config *types.Config) {
 	if config.Settings.SitemapCacheHours == 0 {
 		config.Settings.SitemapCacheHours = 168 // 默认7天
 	}
+	if config.Settings.MaxSitemapDepth == 0 {
+		config.Settings.MaxSitemapDepth = 5 // 默认最多嵌套5层索引
+	}
 	if config.Settings.Timeout == 0 {
 		config.Settings.Timeout = 30 // 默认30秒
 	}
@@ -166,6 +342,25 @@ func setDefaults(config *types.Config) {
 	if config.Settings.LogLevel == "" {
 		config.Settings.LogLevel = "info" // 默认info级别
 	}
+	if config.Settings.Retry.MaxAttempts == 0 {
+		config.Settings.Retry.MaxAttempts = 5 // 默认最多尝试5次
+	}
+	if config.Settings.Retry.BaseDelayMinutes == 0 {
+		config.Settings.Retry.BaseDelayMinutes = 60 // 默认1小时后首次重试
+	}
+	if config.Settings.Retry.MaxDelayHours == 0 {
+		config.Settings.Retry.MaxDelayHours = 24 // 默认最长间隔1天
+	}
+
+	// 未配置限速的平台使用默认值：百度较严格，IndexNow 可以更快
+	if config.Settings.RateLimits == nil {
+		config.Settings.RateLimits = make(map[string]types.RateLimit)
+	}
+	for platform, limit := range defaultRateLimits {
+		if _, ok := config.Settings.RateLimits[platform]; !ok {
+			config.Settings.RateLimits[platform] = limit
+		}
+	}
 
 	// 为每个网站设置默认名称
 	for i := range config.Sites {
@@ -1,165 +1,189 @@
 package history
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"path/filepath"
-	"sync"
+	"net/http"
+	"time"
+
+	"github.com/k12/submit-sitemap/pkg/types"
 )
 
 // Manager 历史记录管理器
+// 记录保存在 HistoryStore 中，默认使用嵌入式键值存储；旧版 <platform>.txt 历史文件在首次加载时自动迁移
 type Manager struct {
 	dataDir string
-	cache   map[string]map[string]map[string]bool // domain -> platform -> url -> bool
-	mu      sync.RWMutex
+	store   HistoryStore
+	lock    *DirLock
 }
 
 // NewManager 创建历史记录管理器
 func NewManager(dataDir string) *Manager {
+	return NewManagerWithStore(dataDir, NewKVStore(dataDir))
+}
+
+// OpenManager 锁定数据目录并创建历史记录管理器，保证同一时间只有一个进程写入历史
+// 数据目录已被其他运行占用时返回 *LockedError（errors.Is(err, ErrLocked) 为真），Close 时释放锁
+func OpenManager(dataDir string) (*Manager, error) {
+	lock, err := LockDataDir(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewManager(dataDir)
+	m.lock = lock
+	return m, nil
+}
+
+// NewManagerWithStore 使用指定的存储创建历史记录管理器
+func NewManagerWithStore(dataDir string, store HistoryStore) *Manager {
 	return &Manager{
 		dataDir: dataDir,
-		cache:   make(map[string]map[string]map[string]bool),
+		store:   store,
 	}
 }
 
 // Load 加载指定站点和平台的历史记录
+// 记录按需从存储读取，这里只负责打开存储并完成旧版历史文件的迁移
 func (m *Manager) Load(domain, platform string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	filePath := m.getFilePath(domain, platform)
+	_, err := m.store.Count(domain, platform)
+	return err
+}
 
-	// 初始化缓存
-	if m.cache[domain] == nil {
-		m.cache[domain] = make(map[string]map[string]bool)
-	}
-	if m.cache[domain][platform] == nil {
-		m.cache[domain][platform] = make(map[string]bool)
+// Get 读取URL的提交记录
+// 读取失败时返回错误，调用方不能把它当作"没有记录"，否则会重复提交
+func (m *Manager) Get(domain, platform, url string) (Record, bool, error) {
+	record, ok, err := m.store.Get(domain, platform, url)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("读取 %s 的提交记录失败: %w", url, err)
 	}
+	return record, ok, nil
+}
 
-	// 如果文件不存在，返回空记录
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil
+// IsSubmitted 检查URL是否已提交
+func (m *Manager) IsSubmitted(domain, platform, url string) (bool, error) {
+	record, ok, err := m.Get(domain, platform, url)
+	if err != nil {
+		return false, err
 	}
+	return ok && record.Submitted(), nil
+}
 
-	// 读取文件
-	file, err := os.Open(filePath)
+// NeedsSubmit 判断URL是否需要提交：从未提交过，或sitemap中的lastmod晚于上次提交时记录的lastmod
+// 上次提交时没有记录lastmod（包括旧版本历史）的URL不会被重新提交
+func (m *Manager) NeedsSubmit(domain, platform string, u types.SitemapURL) (bool, error) {
+	record, ok, err := m.Get(domain, platform, u.Loc)
 	if err != nil {
-		return fmt.Errorf("打开历史文件失败: %w", err)
+		return false, err
 	}
-	defer file.Close()
-
-	// 逐行读取URL
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		url := scanner.Text()
-		if url != "" {
-			m.cache[domain][platform][url] = true
-		}
+	if !ok || !record.Submitted() {
+		return true, nil
 	}
 
-	return scanner.Err()
-}
-
-// IsSubmitted 检查URL是否已提交
-func (m *Manager) IsSubmitted(domain, platform, url string) bool {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	if m.cache[domain] == nil || m.cache[domain][platform] == nil {
-		return false
+	if record.LastMod == "" || u.LastMod == "" {
+		return false, nil
 	}
 
-	return m.cache[domain][platform][url]
+	current, ok1 := u.LastModTime()
+	previous, ok2 := types.ParseLastMod(record.LastMod)
+	if !ok1 || !ok2 {
+		return false, nil
+	}
+	return current.After(previous), nil
 }
 
 // Save 保存成功提交的URL
 func (m *Manager) Save(domain, platform string, urls []string) error {
-	if len(urls) == 0 {
-		return nil
+	entries := make([]types.SitemapURL, len(urls))
+	for i, url := range urls {
+		entries[i] = types.SitemapURL{Loc: url}
 	}
+	return m.SaveEntries(domain, platform, entries)
+}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	filePath := m.getFilePath(domain, platform)
+// SaveEntries 保存成功提交的URL及其提交时的lastmod
+func (m *Manager) SaveEntries(domain, platform string, entries []types.SitemapURL) error {
+	if len(entries) == 0 {
+		return nil
+	}
 
-	// 确保目录存在
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		return fmt.Errorf("创建目录失败: %w", err)
+	now := time.Now()
+	records := make([]Record, 0, len(entries))
+	for _, entry := range entries {
+		record, _, err := m.Get(domain, platform, entry.Loc)
+		if err != nil {
+			return err
+		}
+		record.URL = entry.Loc
+		record.LastMod = entry.LastMod
+		record.SubmittedAt = now
+		record.AttemptedAt = now
+		record.Status = http.StatusOK
+		record.Attempts++
+		records = append(records, record)
 	}
 
-	// 以追加模式打开文件
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("打开历史文件失败: %w", err)
+	return m.store.Put(domain, platform, records)
+}
+
+// RecordFailures 记录提交失败的URL，累加尝试次数并保存状态码，不影响已提交状态
+func (m *Manager) RecordFailures(domain, platform string, urls []string, status int) error {
+	if len(urls) == 0 {
+		return nil
 	}
-	defer file.Close()
 
-	// 写入URL
-	writer := bufio.NewWriter(file)
+	now := time.Now()
+	records := make([]Record, 0, len(urls))
 	for _, url := range urls {
-		if _, err := writer.WriteString(url + "\n"); err != nil {
-			return fmt.Errorf("写入URL失败: %w", err)
+		record, _, err := m.Get(domain, platform, url)
+		if err != nil {
+			return err
 		}
-		// 更新缓存
-		if m.cache[domain] == nil {
-			m.cache[domain] = make(map[string]map[string]bool)
-		}
-		if m.cache[domain][platform] == nil {
-			m.cache[domain][platform] = make(map[string]bool)
-		}
-		m.cache[domain][platform][url] = true
+		record.URL = url
+		record.AttemptedAt = now
+		record.Status = status
+		record.Attempts++
+		records = append(records, record)
 	}
 
-	return writer.Flush()
+	return m.store.Put(domain, platform, records)
 }
 
 // GetCount 获取已提交的URL数量
 func (m *Manager) GetCount(domain, platform string) int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	if m.cache[domain] == nil || m.cache[domain][platform] == nil {
+	count, err := m.store.Count(domain, platform)
+	if err != nil {
 		return 0
 	}
-
-	return len(m.cache[domain][platform])
+	return count
 }
 
 // Reset 清除指定站点的历史记录
 func (m *Manager) Reset(domain string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	domainDir := filepath.Join(m.dataDir, "submitted", domain)
-	if err := os.RemoveAll(domainDir); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("删除历史记录失败: %w", err)
-	}
-
-	// 清除缓存
-	delete(m.cache, domain)
-
-	return nil
+	return m.store.Reset(domain)
 }
 
-// getFilePath 获取历史文件路径
-func (m *Manager) getFilePath(domain, platform string) string {
-	return filepath.Join(m.dataDir, "submitted", domain, platform+".txt")
+// Close 关闭历史存储，并释放 OpenManager 获得的数据目录锁
+func (m *Manager) Close() error {
+	err := m.store.Close()
+	if unlockErr := m.lock.Unlock(); unlockErr != nil && err == nil {
+		err = unlockErr
+	}
+	m.lock = nil
+	return err
 }
 
 // FilterUnsubmitted 过滤出未提交的URL
-func (m *Manager) FilterUnsubmitted(domain, platform string, urls []string) []string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
+func (m *Manager) FilterUnsubmitted(domain, platform string, urls []string) ([]string, error) {
 	var unsubmitted []string
 	for _, url := range urls {
-		if !m.IsSubmitted(domain, platform, url) {
+		submitted, err := m.IsSubmitted(domain, platform, url)
+		if err != nil {
+			return nil, err
+		}
+		if !submitted {
 			unsubmitted = append(unsubmitted, url)
 		}
 	}
 
-	return unsubmitted
+	return unsubmitted, nil
 }
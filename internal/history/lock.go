@@ -0,0 +1,81 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lockFileName 数据目录锁文件
+const lockFileName = ".lock"
+
+// ErrLocked 数据目录已被另一个运行中的进程锁定
+var ErrLocked = errors.New("数据目录已被其他进程占用")
+
+// LockedError 数据目录被锁定时返回的错误，包含持有锁的进程信息
+type LockedError struct {
+	Path string // 锁文件路径
+	PID  int    // 持有锁的进程ID，未知时为0
+}
+
+// Error 实现error接口
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("%v: %s (进程 %d)，请等待其结束后再运行", ErrLocked, e.Path, e.PID)
+	}
+	return fmt.Sprintf("%v: %s，请等待其结束后再运行", ErrLocked, e.Path)
+}
+
+// Unwrap 支持 errors.Is(err, ErrLocked)
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// DirLock 数据目录的咨询锁，防止多个进程同时写入历史记录
+type DirLock struct {
+	path string
+	file *os.File
+}
+
+// LockDataDir 锁定数据目录，目录已被其他进程锁定时立即返回 *LockedError
+func LockDataDir(dataDir string) (*DirLock, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建数据目录失败: %w", err)
+	}
+
+	path := filepath.Join(dataDir, lockFileName)
+	file, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 记录当前进程ID，便于提示谁持有锁
+	file.Truncate(0)
+	file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	file.Sync()
+
+	return &DirLock{path: path, file: file}, nil
+}
+
+// Unlock 释放锁
+func (l *DirLock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := unlockFile(l.path, l.file)
+	l.file = nil
+	return err
+}
+
+// lockHolder 读取锁文件中记录的进程ID
+func lockHolder(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
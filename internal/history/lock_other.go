@@ -0,0 +1,29 @@
+//go:build !unix
+
+package history
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockFile 以独占方式创建锁文件；进程崩溃后残留的锁文件需要手动删除
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if os.IsExist(err) {
+		return nil, &LockedError{Path: path, PID: lockHolder(path)}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("锁定数据目录失败: %w", err)
+	}
+	return file, nil
+}
+
+// unlockFile 释放锁并删除锁文件
+func unlockFile(path string, file *os.File) error {
+	err := file.Close()
+	if removeErr := os.Remove(path); removeErr != nil && err == nil {
+		err = removeErr
+	}
+	return err
+}
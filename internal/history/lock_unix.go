@@ -0,0 +1,33 @@
+//go:build unix
+
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile 打开锁文件并加 flock 排他锁，进程退出（包括崩溃）时锁自动释放
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开锁文件失败: %w", err)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, &LockedError{Path: path, PID: lockHolder(path)}
+		}
+		return nil, fmt.Errorf("锁定数据目录失败: %w", err)
+	}
+	return file, nil
+}
+
+// unlockFile 释放锁，锁文件保留以免与正在加锁的进程竞争
+func unlockFile(path string, file *os.File) error {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	return file.Close()
+}
@@ -0,0 +1,92 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/k12/submit-sitemap/internal/kvstore"
+)
+
+// migrateBatchSize 迁移时每批写入的记录数
+const migrateBatchSize = 10000
+
+// migrateTextHistory 将旧版 <platform>.txt 历史文件导入存储
+// 每行 "URL" 或 "URL\tlastmod"，提交时间取文件修改时间，不完整的最后一行被忽略；导入完成后文件重命名为 .txt.migrated，
+// 中途失败时保留原文件，下次打开时重新导入（重复导入结果相同）
+func migrateTextHistory(db *kvstore.DB, textPath string) error {
+	info, err := os.Stat(textPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取历史文件失败: %w", err)
+	}
+
+	file, err := os.Open(textPath)
+	if err != nil {
+		return fmt.Errorf("打开历史文件失败: %w", err)
+	}
+	defer file.Close()
+
+	submittedAt := info.ModTime()
+	batch := make(map[string][]byte, migrateBatchSize)
+	flush := func() error {
+		if err := db.PutBatch(batch); err != nil {
+			return fmt.Errorf("迁移历史记录失败: %w", err)
+		}
+		batch = make(map[string][]byte, migrateBatchSize)
+		return nil
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			// 没有换行结尾的最后一行可能是崩溃时写了一半的URL，丢弃
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取历史文件失败: %w", err)
+		}
+
+		url, lastMod, _ := strings.Cut(strings.TrimRight(line, "\r\n"), "\t")
+		if url == "" {
+			continue
+		}
+		data, err := json.Marshal(Record{
+			LastMod:     lastMod,
+			SubmittedAt: submittedAt,
+			AttemptedAt: submittedAt,
+			Status:      http.StatusOK,
+			Attempts:    1,
+		})
+		if err != nil {
+			return fmt.Errorf("序列化历史记录失败: %w", err)
+		}
+		batch[url] = data
+		if len(batch) >= migrateBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	// 计数需要重新统计
+	if err := db.Delete(countKey); err != nil {
+		return err
+	}
+
+	file.Close()
+	if err := os.Rename(textPath, textPath+".migrated"); err != nil {
+		return fmt.Errorf("重命名历史文件失败: %w", err)
+	}
+	return nil
+}
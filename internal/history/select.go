@@ -0,0 +1,120 @@
+package history
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// defaultPriority sitemap协议规定的默认priority
+const defaultPriority = 0.5
+
+// SelectForSubmit 选出需要提交的URL（未提交或lastmod有更新）并按优先级排序
+// limit 大于0时只返回前 limit 条，用于按每日配额截取；读取历史失败时返回错误，不做提交
+func (m *Manager) SelectForSubmit(domain, platform string, urls []types.SitemapURL, limit int) ([]types.SitemapURL, error) {
+	var selected []types.SitemapURL
+	for _, u := range urls {
+		needs, err := m.NeedsSubmit(domain, platform, u)
+		if err != nil {
+			return nil, err
+		}
+		if needs {
+			selected = append(selected, u)
+		}
+	}
+
+	Prioritize(selected)
+
+	if limit > 0 && len(selected) > limit {
+		selected = selected[:limit]
+	}
+	return selected, nil
+}
+
+// SelectBaiduSplit 为百度普通收录（baidu）和快速收录（baidu_daily）选择同一次运行要提交的URL：
+// 快速收录先按 dailyLimit 选出优先级最高的URL，普通收录只从其余URL中按 limit 选取，
+// 同一URL在一次运行中只提交到其中一个引擎。limit、dailyLimit 为各自当天的剩余配额，不大于0时该引擎不选取URL；
+// 两个引擎的历史记录仍分开计算
+func (m *Manager) SelectBaiduSplit(domain string, urls []types.SitemapURL, limit, dailyLimit int) (baidu, daily []types.SitemapURL, err error) {
+	if dailyLimit > 0 {
+		daily, err = m.SelectForSubmit(domain, "baidu_daily", urls, dailyLimit)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if limit > 0 {
+		baidu, err = m.SelectForSubmit(domain, "baidu", ExcludeURLs(urls, daily), limit)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return baidu, daily, nil
+}
+
+// ExcludeURLs 返回 urls 中不在 exclude 里的URL（按 Loc 比较），保持原有顺序
+// 接入重试队列时，普通收录传给 retryqueue.Queue.Select 的URL同样需要排除快速收录已选的URL
+func ExcludeURLs(urls, exclude []types.SitemapURL) []types.SitemapURL {
+	if len(exclude) == 0 {
+		return urls
+	}
+
+	skip := make(map[string]bool, len(exclude))
+	for _, u := range exclude {
+		skip[u.Loc] = true
+	}
+
+	result := make([]types.SitemapURL, 0, len(urls))
+	for _, u := range urls {
+		if !skip[u.Loc] {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// Prioritize 按提交优先级原地排序：
+// 来源权重高的在前；其次lastmod越新越靠前，没有lastmod的排在有lastmod的之后；
+// 最后按priority从高到低。各项相同时保持原有顺序
+func Prioritize(urls []types.SitemapURL) {
+	type sortKey struct {
+		hasLastMod bool
+		lastMod    int64
+		priority   float64
+	}
+
+	keys := make(map[string]sortKey, len(urls))
+	for _, u := range urls {
+		key := sortKey{priority: parsePriority(u.Priority)}
+		if t, ok := u.LastModTime(); ok {
+			key.hasLastMod = true
+			key.lastMod = t.Unix()
+		}
+		keys[u.Loc] = key
+	}
+
+	sort.SliceStable(urls, func(i, j int) bool {
+		if urls[i].Weight != urls[j].Weight {
+			return urls[i].Weight > urls[j].Weight
+		}
+		a, b := keys[urls[i].Loc], keys[urls[j].Loc]
+		if a.hasLastMod != b.hasLastMod {
+			return a.hasLastMod
+		}
+		if a.lastMod != b.lastMod {
+			return a.lastMod > b.lastMod
+		}
+		return a.priority > b.priority
+	})
+}
+
+// parsePriority 解析priority，缺失或无效时使用默认值
+func parsePriority(value string) float64 {
+	p, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || p < 0 || p > 1 {
+		return defaultPriority
+	}
+	return p
+}
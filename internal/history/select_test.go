@@ -0,0 +1,63 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// locs 返回URL地址列表
+func locs(urls []types.SitemapURL) []string {
+	result := make([]string, len(urls))
+	for i, u := range urls {
+		result[i] = u.Loc
+	}
+	return result
+}
+
+func TestSelectBaiduSplit(t *testing.T) {
+	m := NewManager(t.TempDir())
+	t.Cleanup(func() { m.Close() })
+
+	const domain = "example.com"
+
+	// 权重越大越优先：/0 最优先，/5 最后
+	var urls []types.SitemapURL
+	for i := 0; i < 6; i++ {
+		urls = append(urls, types.SitemapURL{Loc: fmt.Sprintf("https://example.com/%d", i), Weight: 10 - i})
+	}
+
+	// /0 之前已提交到快速收录，/1 之前已提交到普通收录
+	if err := m.Save(domain, "baidu_daily", []string{urls[0].Loc}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Save(domain, "baidu", []string{urls[1].Loc}); err != nil {
+		t.Fatal(err)
+	}
+
+	baidu, daily, err := m.SelectBaiduSplit(domain, urls, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 快速收录先选：/0 已提交过，选 /1、/2；普通收录跳过快速收录已选的 /1、/2
+	if got, want := fmt.Sprint(locs(daily)), fmt.Sprint([]string{urls[1].Loc, urls[2].Loc}); got != want {
+		t.Errorf("daily = %v, 应为 %v", got, want)
+	}
+	if got, want := fmt.Sprint(locs(baidu)), fmt.Sprint([]string{urls[0].Loc, urls[3].Loc, urls[4].Loc}); got != want {
+		t.Errorf("baidu = %v, 应为 %v", got, want)
+	}
+
+	// 未启用快速收录时普通收录照常选取
+	baidu, daily, err = m.SelectBaiduSplit(domain, urls, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 0 {
+		t.Errorf("daily = %v, 未启用时应为空", locs(daily))
+	}
+	if got, want := fmt.Sprint(locs(baidu)), fmt.Sprint([]string{urls[0].Loc, urls[2].Loc}); got != want {
+		t.Errorf("baidu = %v, 应为 %v", got, want)
+	}
+}
@@ -0,0 +1,244 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/k12/submit-sitemap/internal/kvstore"
+)
+
+// countKey 保存已成功提交URL数量的键，URL不会以空字节开头
+const countKey = "\x00submitted_count"
+
+// Record 单个URL在某个平台上的提交记录
+type Record struct {
+	URL         string    `json:"-"`
+	LastMod     string    `json:"lastmod,omitempty"`     // 最近一次成功提交时sitemap中的lastmod
+	SubmittedAt time.Time `json:"submitted_at,omitzero"` // 最近一次成功提交时间，零值表示从未成功
+	AttemptedAt time.Time `json:"attempted_at,omitzero"` // 最近一次尝试提交时间
+	Status      int       `json:"status,omitempty"`      // 最近一次提交的HTTP状态码
+	Attempts    int       `json:"attempts,omitempty"`    // 累计提交次数
+}
+
+// Submitted 是否已成功提交过
+func (r Record) Submitted() bool {
+	return !r.SubmittedAt.IsZero()
+}
+
+// HistoryStore 提交历史存储接口
+type HistoryStore interface {
+	// Get 读取记录，不存在时返回 false
+	Get(domain, platform, url string) (Record, bool, error)
+	// Put 批量写入记录
+	Put(domain, platform string, records []Record) error
+	// Count 返回已成功提交的URL数量
+	Count(domain, platform string) (int, error)
+	// Reset 删除站点的全部记录
+	Reset(domain string) error
+	// Close 关闭存储
+	Close() error
+}
+
+// KVStore 基于嵌入式键值存储的历史记录，每个站点的每个平台一个存储，
+// 位于 <dataDir>/submitted/<domain>/<platform>.db
+type KVStore struct {
+	dataDir string
+	dbs     map[string]*kvstore.DB
+	counts  map[string]int // 已成功提交的URL数量
+	mu      sync.Mutex
+}
+
+// NewKVStore 创建键值存储历史记录
+func NewKVStore(dataDir string) *KVStore {
+	return &KVStore{
+		dataDir: dataDir,
+		dbs:     make(map[string]*kvstore.DB),
+		counts:  make(map[string]int),
+	}
+}
+
+// Get 读取记录
+func (s *KVStore) Get(domain, platform, url string) (Record, bool, error) {
+	db, err := s.open(domain, platform)
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	data, err := db.Get(url)
+	if errors.Is(err, kvstore.ErrNotFound) {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return Record{}, false, fmt.Errorf("解析历史记录失败: %w", err)
+	}
+	record.URL = url
+	return record, true, nil
+}
+
+// Put 批量写入记录
+func (s *KVStore) Put(domain, platform string, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	db, err := s.open(domain, platform)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make(map[string][]byte, len(records))
+	added := 0
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("序列化历史记录失败: %w", err)
+		}
+		if _, seen := entries[record.URL]; !seen && record.Submitted() && !s.submitted(db, record.URL) {
+			added++
+		}
+		entries[record.URL] = data
+	}
+
+	key := storeKey(domain, platform)
+	count := s.counts[key] + added
+	entries[countKey] = []byte(strconv.Itoa(count))
+	if err := db.PutBatch(entries); err != nil {
+		return err
+	}
+	s.counts[key] = count
+	return nil
+}
+
+// Count 返回已成功提交的URL数量
+func (s *KVStore) Count(domain, platform string) (int, error) {
+	if _, err := s.open(domain, platform); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[storeKey(domain, platform)], nil
+}
+
+// Reset 删除站点的全部记录
+func (s *KVStore) Reset(domain string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := domain + "/"
+	for key, db := range s.dbs {
+		if len(key) > len(prefix) && key[:len(prefix)] == prefix {
+			db.Close()
+			delete(s.dbs, key)
+			delete(s.counts, key)
+		}
+	}
+
+	domainDir := filepath.Join(s.dataDir, "submitted", domain)
+	if err := os.RemoveAll(domainDir); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除历史记录失败: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭全部存储
+func (s *KVStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for key, db := range s.dbs {
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.dbs, key)
+	}
+	return firstErr
+}
+
+// open 打开站点平台对应的存储，首次打开时迁移旧版 .txt 历史文件
+func (s *KVStore) open(domain, platform string) (*kvstore.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := storeKey(domain, platform)
+	if db, ok := s.dbs[key]; ok {
+		return db, nil
+	}
+
+	dir := filepath.Join(s.dataDir, "submitted", domain)
+	db, err := kvstore.Open(filepath.Join(dir, platform+".db"))
+	if err != nil {
+		return nil, fmt.Errorf("打开历史记录失败: %w", err)
+	}
+
+	if err := migrateTextHistory(db, filepath.Join(dir, platform+".txt")); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	count, err := loadCount(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s.dbs[key] = db
+	s.counts[key] = count
+	return db, nil
+}
+
+// loadCount 读取已成功提交的URL数量，计数缺失时遍历记录重新统计
+func loadCount(db *kvstore.DB) (int, error) {
+	if data, err := db.Get(countKey); err == nil {
+		if count, err := strconv.Atoi(string(data)); err == nil {
+			return count, nil
+		}
+	}
+
+	count := 0
+	err := db.ForEach(func(url string, data []byte) error {
+		var record Record
+		if url != countKey && json.Unmarshal(data, &record) == nil && record.Submitted() {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("读取历史记录失败: %w", err)
+	}
+
+	if err := db.Put(countKey, []byte(strconv.Itoa(count))); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// submitted 判断URL是否已有成功提交的记录
+func (s *KVStore) submitted(db *kvstore.DB, url string) bool {
+	data, err := db.Get(url)
+	if err != nil {
+		return false
+	}
+	var record Record
+	return json.Unmarshal(data, &record) == nil && record.Submitted()
+}
+
+// storeKey 存储实例的键
+func storeKey(domain, platform string) string {
+	return domain + "/" + platform
+}
@@ -0,0 +1,273 @@
+// Package httpretry 带重试的HTTP传输层
+//
+// 连接错误、超时以及 429/500/502/503/504 响应按指数退避加随机抖动重试，
+// 429/503 响应中的 Retry-After 优先于计算出的等待时间。POST 等非幂等请求
+// 超时或连接中断时服务端可能已经处理，重复提交会重复消耗配额，因此只重试
+// 请求发出前的连接错误和明确表示未处理的 429/503 响应；提交器还可以通过
+// WithoutRetry 对单个请求关闭重试。WithRateLimit 让每次发送（含重试）都经过限速。
+package httpretry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 默认重试参数
+const (
+	DefaultMaxRetries    = 3
+	DefaultBaseDelay     = 500 * time.Millisecond
+	DefaultMaxDelay      = 30 * time.Second
+	DefaultMaxRetryAfter = 2 * time.Minute
+)
+
+// Transport 带重试的 http.RoundTripper
+type Transport struct {
+	Base           http.RoundTripper // 底层传输，为空时使用 http.DefaultTransport
+	MaxRetries     int               // 最多重试次数，不含首次请求
+	BaseDelay      time.Duration     // 首次重试的基础等待时间，之后每次翻倍
+	MaxDelay       time.Duration     // 计算出的等待时间上限
+	MaxRetryAfter  time.Duration     // 接受的 Retry-After 上限，超过时不再重试，直接返回响应
+	AttemptTimeout time.Duration     // 单次请求（含读取响应体）的超时时间，0表示不限制
+}
+
+// NewClient 创建使用默认重试参数的HTTP客户端
+// 超时作用于每一次请求而不是整个重试过程，避免等待 Retry-After 时被提前取消
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &Transport{
+			MaxRetries:     DefaultMaxRetries,
+			BaseDelay:      DefaultBaseDelay,
+			MaxDelay:       DefaultMaxDelay,
+			MaxRetryAfter:  DefaultMaxRetryAfter,
+			AttemptTimeout: timeout,
+		},
+	}
+}
+
+// Counter 统计请求实际发送的次数（含重试）
+type Counter struct {
+	n atomic.Int64
+}
+
+// Attempts 返回已发送的次数
+func (c *Counter) Attempts() int {
+	return int(c.n.Load())
+}
+
+type counterKey struct{}
+
+// WithCounter 返回附带计数器的 context，使用该 context 的请求每发送一次计数加一
+func WithCounter(ctx context.Context) (context.Context, *Counter) {
+	counter := &Counter{}
+	return context.WithValue(ctx, counterKey{}, counter), counter
+}
+
+type noRetryKey struct{}
+
+// WithoutRetry 返回关闭重试的 context，使用该 context 的请求只发送一次，
+// 适用于重复发送会产生副作用、且失败由调用方自行处理的接口
+func WithoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+type rateLimitKey struct{}
+
+// WithRateLimit 返回附带限速函数的 context，使用该 context 的请求每次发送前（含重试）
+// 先调用 wait 等待，wait 返回错误时不再发送
+func WithRateLimit(ctx context.Context, wait func(context.Context) error) context.Context {
+	return context.WithValue(ctx, rateLimitKey{}, wait)
+}
+
+// RoundTrip 发送请求，可重试的失败按退避策略重试
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx := req.Context()
+	counter, _ := ctx.Value(counterKey{}).(*Counter)
+	noRetry, _ := ctx.Value(noRetryKey{}).(bool)
+	wait, _ := ctx.Value(rateLimitKey{}).(func(context.Context) error)
+	idempotent := isIdempotent(req.Method)
+
+	// 请求体无法重放时只发送一次
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		if wait != nil {
+			if err := wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		attemptReq, cancel, err := t.prepare(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		if counter != nil {
+			counter.n.Add(1)
+		}
+
+		resp, err := base.RoundTrip(attemptReq)
+		last := noRetry || attempt >= t.MaxRetries || !replayable || ctx.Err() != nil
+
+		if err != nil {
+			cancel()
+			if last || !retryableError(err, idempotent) {
+				return nil, err
+			}
+			if err := sleep(ctx, t.backoff(attempt)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !retryableStatus(resp.StatusCode, idempotent) || last {
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		delay := t.backoff(attempt)
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if t.MaxRetryAfter > 0 && wait > t.MaxRetryAfter {
+				// 等待时间过长，交给调用方处理
+				resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+				return resp, nil
+			}
+			delay = wait
+		}
+
+		// 读完并关闭响应体，以便复用连接
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		cancel()
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// prepare 为第 attempt 次请求准备请求对象：重放请求体并设置单次超时
+func (t *Transport) prepare(req *http.Request, attempt int) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.AttemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.AttemptTimeout)
+	}
+
+	attemptReq := req.WithContext(ctx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, cancel, nil
+}
+
+// backoff 第 attempt 次重试前的等待时间：基础时间逐次翻倍，取一半固定加一半随机
+func (t *Transport) backoff(attempt int) time.Duration {
+	delay := t.BaseDelay
+	for i := 0; i < attempt; i++ {
+		delay *= 2
+		if t.MaxDelay > 0 && delay >= t.MaxDelay {
+			delay = t.MaxDelay
+			break
+		}
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// isIdempotent 重复发送不会产生额外副作用的请求方法
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryableStatus 可重试的HTTP状态码
+// 非幂等请求只重试 429/503：服务端明确表示没有处理该请求；500/502/504 时可能已经处理
+func retryableStatus(code int, idempotent bool) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// retryableError 可重试的错误：幂等请求重试网络错误（连接被重置、超时等）和连接意外断开；
+// 非幂等请求只重试建立连接阶段（含DNS解析）的错误，此时请求还没有发出
+func retryableError(err error, idempotent bool) bool {
+	if !idempotent {
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfter 解析 Retry-After，支持秒数和HTTP日期两种格式
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if wait := t.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleep 等待指定时间，context 取消时提前返回
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cancelBody 关闭响应体时释放单次请求的超时 context
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close 关闭响应体
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,639 @@
+// Package kvstore 嵌入式键值存储
+//
+// 数据以追加日志的形式写入 data.log，每条记录带CRC校验，每次写入后fsync；
+// 崩溃留下的不完整记录在打开时被截掉，不会被当作有效数据。内存中只保留
+// 键的64位哈希到日志偏移量的索引，值按需从磁盘读取。关闭时写出 data.hint
+// 索引快照，下次打开时无需扫描整个日志即可恢复索引。
+package kvstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	logFileName  = "data.log"
+	hintFileName = "data.hint"
+
+	headerSize   = 13 // crc(4) + keyLen(4) + valLen(4) + flags(1)
+	flagDeleted  = 1
+	hintMagic    = "KVHINT01"
+	maxKeyLength = 1 << 16
+)
+
+// ErrNotFound 键不存在
+var ErrNotFound = errors.New("键不存在")
+
+// errCorruptRecord 记录头中的长度超出日志有效范围
+var errCorruptRecord = errors.New("读取存储失败: 记录已损坏")
+
+// DB 单个键值存储实例
+type DB struct {
+	dir     string
+	log     *os.File
+	size    int64            // 日志文件有效长度
+	index   map[uint64]int64 // 键哈希 -> 记录偏移量
+	overlap map[string]int64 // 哈希冲突的键单独保存
+	garbage int64            // 被覆盖或删除的记录占用的字节数
+	mu      sync.RWMutex
+}
+
+// Open 打开（或创建）目录下的存储
+func Open(dir string) (*DB, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+
+	log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开存储文件失败: %w", err)
+	}
+
+	db := &DB{
+		dir:     dir,
+		log:     log,
+		index:   make(map[uint64]int64),
+		overlap: make(map[string]int64),
+	}
+
+	if err := db.recover(); err != nil {
+		log.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// recover 从索引快照和日志恢复内存索引
+func (db *DB) recover() error {
+	info, err := db.log.Stat()
+	if err != nil {
+		return fmt.Errorf("读取存储文件失败: %w", err)
+	}
+
+	start := int64(0)
+	if snapshot, ok := db.loadHint(info.Size()); ok {
+		start = snapshot
+	} else {
+		db.index = make(map[uint64]int64)
+		db.overlap = make(map[string]int64)
+		db.garbage = 0
+	}
+
+	// 快照之后追加的记录需要重新扫描；扫描期间按文件长度校验已索引的记录
+	db.size = info.Size()
+	end, err := db.scan(start, info.Size())
+	if err != nil {
+		return err
+	}
+	db.size = end
+
+	// 截掉崩溃时写了一半的记录，之后的追加从有效末尾开始
+	if end < info.Size() {
+		if err := db.log.Truncate(end); err != nil {
+			return fmt.Errorf("修复存储文件失败: %w", err)
+		}
+		if err := db.log.Sync(); err != nil {
+			return fmt.Errorf("修复存储文件失败: %w", err)
+		}
+	}
+
+	// 快照已过期，删除避免下次误用
+	os.Remove(filepath.Join(db.dir, hintFileName))
+	return nil
+}
+
+// scan 顺序读取日志记录并更新索引，遇到不完整或校验失败的记录时停止，返回有效长度
+func (db *DB) scan(start, limit int64) (int64, error) {
+	reader := bufio.NewReaderSize(io.NewSectionReader(db.log, start, limit-start), 256*1024)
+	offset := start
+	header := make([]byte, headerSize)
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return offset, nil
+		}
+
+		sum := binary.LittleEndian.Uint32(header[0:4])
+		keyLen := binary.LittleEndian.Uint32(header[4:8])
+		valLen := binary.LittleEndian.Uint32(header[8:12])
+		flags := header[12]
+		if keyLen == 0 || keyLen > maxKeyLength {
+			return offset, nil
+		}
+		// 长度超出文件剩余部分说明记录头已损坏，不能按其分配内存
+		if int64(keyLen)+int64(valLen) > limit-offset-headerSize {
+			return offset, nil
+		}
+
+		payload := make([]byte, int(keyLen)+int(valLen))
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return offset, nil
+		}
+
+		crc := crc32.NewIEEE()
+		crc.Write(header[4:])
+		crc.Write(payload)
+		if crc.Sum32() != sum {
+			return offset, nil
+		}
+
+		key := string(payload[:keyLen])
+		recordSize := int64(headerSize) + int64(len(payload))
+		if flags&flagDeleted != 0 {
+			if old, ok := db.lookup(key); ok {
+				db.garbage += db.recordSizeAt(old)
+				db.remove(key)
+			}
+			db.garbage += recordSize
+		} else {
+			if old, ok := db.lookup(key); ok {
+				db.garbage += db.recordSizeAt(old)
+			}
+			db.setOffset(key, offset)
+		}
+
+		offset += recordSize
+	}
+}
+
+// Get 读取键对应的值
+func (db *DB) Get(key string) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	offset, ok := db.lookup(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	_, value, err := db.readAt(offset)
+	return value, err
+}
+
+// Has 判断键是否存在
+func (db *DB) Has(key string) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	_, ok := db.lookup(key)
+	return ok
+}
+
+// Len 返回键的数量
+func (db *DB) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return len(db.index) + len(db.overlap)
+}
+
+// Put 写入单个键值
+func (db *DB) Put(key string, value []byte) error {
+	return db.PutBatch(map[string][]byte{key: value})
+}
+
+// PutBatch 批量写入键值，一次追加写入日志
+func (db *DB) PutBatch(entries map[string][]byte) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var buf bytes.Buffer
+	offsets := make(map[string]int64, len(entries))
+	for key, value := range entries {
+		if len(key) == 0 || len(key) > maxKeyLength {
+			return fmt.Errorf("无效的键长度: %d", len(key))
+		}
+		offsets[key] = db.size + int64(buf.Len())
+		encodeRecord(&buf, key, value, 0)
+	}
+
+	// 先写入并落盘，成功后才更新索引；写入失败时截掉可能写了一半的记录
+	if err := db.appendSync(buf.Bytes()); err != nil {
+		return err
+	}
+
+	for key, offset := range offsets {
+		if old, ok := db.lookup(key); ok {
+			db.garbage += db.recordSizeAt(old)
+		}
+		db.setOffset(key, offset)
+	}
+
+	return nil
+}
+
+// Delete 删除键
+func (db *DB) Delete(key string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	old, ok := db.lookup(key)
+	if !ok {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encodeRecord(&buf, key, nil, flagDeleted)
+	if err := db.appendSync(buf.Bytes()); err != nil {
+		return err
+	}
+	db.garbage += db.recordSizeAt(old) + int64(buf.Len())
+	db.remove(key)
+
+	return nil
+}
+
+// ForEach 遍历全部键值，顺序不固定；fn返回错误时停止遍历
+func (db *DB) ForEach(fn func(key string, value []byte) error) error {
+	db.mu.RLock()
+	offsets := make([]int64, 0, len(db.index)+len(db.overlap))
+	for _, offset := range db.index {
+		offsets = append(offsets, offset)
+	}
+	for _, offset := range db.overlap {
+		offsets = append(offsets, offset)
+	}
+	db.mu.RUnlock()
+
+	for _, offset := range offsets {
+		db.mu.RLock()
+		key, value, err := db.readAt(offset)
+		db.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 关闭存储，必要时压缩日志，并写出索引快照
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.log == nil {
+		return nil
+	}
+
+	// 失效记录超过一半时压缩
+	if db.garbage > 0 && db.garbage*2 > db.size {
+		if err := db.compact(); err != nil {
+			db.log.Close()
+			db.log = nil
+			return err
+		}
+	}
+
+	hintErr := db.writeHint()
+	err := db.log.Close()
+	db.log = nil
+	if err != nil {
+		return fmt.Errorf("关闭存储失败: %w", err)
+	}
+	return hintErr
+}
+
+// appendSync 追加数据到日志末尾并fsync，调用方需持有写锁
+func (db *DB) appendSync(data []byte) error {
+	if _, err := db.log.WriteAt(data, db.size); err != nil {
+		db.log.Truncate(db.size)
+		return fmt.Errorf("写入存储失败: %w", err)
+	}
+	if err := db.log.Sync(); err != nil {
+		db.log.Truncate(db.size)
+		return fmt.Errorf("写入存储失败: %w", err)
+	}
+	db.size += int64(len(data))
+	return nil
+}
+
+// lookup 查找键的偏移量，调用方需持有锁
+func (db *DB) lookup(key string) (int64, bool) {
+	if offset, ok := db.overlap[key]; ok {
+		return offset, true
+	}
+	offset, ok := db.index[hashKey(key)]
+	if !ok {
+		return 0, false
+	}
+	// 哈希命中时确认键一致，排除冲突
+	stored, err := db.keyAt(offset)
+	if err != nil || stored != key {
+		return 0, false
+	}
+	return offset, true
+}
+
+// setOffset 更新键的偏移量，调用方需持有写锁
+func (db *DB) setOffset(key string, offset int64) {
+	if _, ok := db.overlap[key]; ok {
+		db.overlap[key] = offset
+		return
+	}
+	h := hashKey(key)
+	if existing, ok := db.index[h]; ok {
+		if stored, err := db.keyAt(existing); err == nil && stored != key {
+			db.overlap[key] = offset
+			return
+		}
+	}
+	db.index[h] = offset
+}
+
+// remove 从索引中删除键，调用方需持有写锁
+func (db *DB) remove(key string) {
+	if _, ok := db.overlap[key]; ok {
+		delete(db.overlap, key)
+		return
+	}
+	delete(db.index, hashKey(key))
+}
+
+// keyAt 读取偏移量处记录的键
+func (db *DB) keyAt(offset int64) (string, error) {
+	header := make([]byte, headerSize)
+	if _, err := db.log.ReadAt(header, offset); err != nil {
+		return "", fmt.Errorf("读取存储失败: %w", err)
+	}
+	keyLen := binary.LittleEndian.Uint32(header[4:8])
+	if keyLen > maxKeyLength || offset+headerSize+int64(keyLen) > db.size {
+		return "", errCorruptRecord
+	}
+	key := make([]byte, keyLen)
+	if _, err := db.log.ReadAt(key, offset+headerSize); err != nil {
+		return "", fmt.Errorf("读取存储失败: %w", err)
+	}
+	return string(key), nil
+}
+
+// readAt 读取偏移量处的完整记录
+func (db *DB) readAt(offset int64) (string, []byte, error) {
+	header := make([]byte, headerSize)
+	if _, err := db.log.ReadAt(header, offset); err != nil {
+		return "", nil, fmt.Errorf("读取存储失败: %w", err)
+	}
+	keyLen := binary.LittleEndian.Uint32(header[4:8])
+	valLen := binary.LittleEndian.Uint32(header[8:12])
+	if keyLen > maxKeyLength || offset+headerSize+int64(keyLen)+int64(valLen) > db.size {
+		return "", nil, errCorruptRecord
+	}
+
+	payload := make([]byte, int(keyLen)+int(valLen))
+	if _, err := db.log.ReadAt(payload, offset+headerSize); err != nil {
+		return "", nil, fmt.Errorf("读取存储失败: %w", err)
+	}
+	return string(payload[:keyLen]), payload[keyLen:], nil
+}
+
+// recordSizeAt 返回偏移量处记录占用的字节数
+func (db *DB) recordSizeAt(offset int64) int64 {
+	header := make([]byte, headerSize)
+	if _, err := db.log.ReadAt(header, offset); err != nil {
+		return 0
+	}
+	keyLen := binary.LittleEndian.Uint32(header[4:8])
+	valLen := binary.LittleEndian.Uint32(header[8:12])
+	return int64(headerSize) + int64(keyLen) + int64(valLen)
+}
+
+// compact 只保留有效记录重写日志，调用方需持有写锁
+func (db *DB) compact() error {
+	tmpPath := filepath.Join(db.dir, logFileName+".compact")
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("压缩存储失败: %w", err)
+	}
+
+	writer := bufio.NewWriterSize(tmp, 256*1024)
+	newIndex := make(map[uint64]int64, len(db.index))
+	newOverlap := make(map[string]int64, len(db.overlap))
+	var offset int64
+	var buf bytes.Buffer
+
+	copyRecord := func(old int64) (int64, error) {
+		key, value, err := db.readAt(old)
+		if err != nil {
+			return 0, err
+		}
+		buf.Reset()
+		encodeRecord(&buf, key, value, 0)
+		if _, err := writer.Write(buf.Bytes()); err != nil {
+			return 0, err
+		}
+		at := offset
+		offset += int64(buf.Len())
+		return at, nil
+	}
+
+	for h, old := range db.index {
+		at, err := copyRecord(old)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("压缩存储失败: %w", err)
+		}
+		newIndex[h] = at
+	}
+	for key, old := range db.overlap {
+		at, err := copyRecord(old)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("压缩存储失败: %w", err)
+		}
+		newOverlap[key] = at
+	}
+
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("压缩存储失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("压缩存储失败: %w", err)
+	}
+
+	logPath := filepath.Join(db.dir, logFileName)
+	if err := os.Rename(tmpPath, logPath); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("压缩存储失败: %w", err)
+	}
+
+	syncDir(db.dir)
+	db.log.Close()
+	db.log = tmp
+	db.size = offset
+	db.index = newIndex
+	db.overlap = newOverlap
+	db.garbage = 0
+	return nil
+}
+
+// writeHint 写出索引快照，调用方需持有写锁
+func (db *DB) writeHint() error {
+	var buf bytes.Buffer
+	buf.WriteString(hintMagic)
+	binary.Write(&buf, binary.LittleEndian, db.size)
+	binary.Write(&buf, binary.LittleEndian, db.garbage)
+	binary.Write(&buf, binary.LittleEndian, uint64(len(db.index)))
+	for h, offset := range db.index {
+		binary.Write(&buf, binary.LittleEndian, h)
+		binary.Write(&buf, binary.LittleEndian, offset)
+	}
+	binary.Write(&buf, binary.LittleEndian, uint64(len(db.overlap)))
+	for key, offset := range db.overlap {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(key)))
+		buf.WriteString(key)
+		binary.Write(&buf, binary.LittleEndian, offset)
+	}
+	binary.Write(&buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()))
+
+	hintPath := filepath.Join(db.dir, hintFileName)
+	if err := writeFileSync(hintPath, buf.Bytes()); err != nil {
+		return fmt.Errorf("写入索引快照失败: %w", err)
+	}
+	return nil
+}
+
+// writeFileSync 写入临时文件并fsync后重命名，保证文件要么是旧内容要么是完整的新内容
+func writeFileSync(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// syncDir 将目录项落盘，使重命名在崩溃后依然有效；部分平台不支持，忽略错误
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// loadHint 读取索引快照，返回快照对应的日志长度；快照无效或与日志不匹配时返回 false
+func (db *DB) loadHint(logSize int64) (int64, bool) {
+	data, err := os.ReadFile(filepath.Join(db.dir, hintFileName))
+	if err != nil || len(data) < len(hintMagic)+4 || string(data[:len(hintMagic)]) != hintMagic {
+		return 0, false
+	}
+
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return 0, false
+	}
+
+	reader := bytes.NewReader(body[len(hintMagic):])
+	var size, garbage int64
+	var count uint64
+	if binary.Read(reader, binary.LittleEndian, &size) != nil || size > logSize {
+		return 0, false
+	}
+	if binary.Read(reader, binary.LittleEndian, &garbage) != nil {
+		return 0, false
+	}
+
+	index := make(map[uint64]int64)
+	if binary.Read(reader, binary.LittleEndian, &count) != nil {
+		return 0, false
+	}
+	for i := uint64(0); i < count; i++ {
+		var h uint64
+		var offset int64
+		if binary.Read(reader, binary.LittleEndian, &h) != nil || binary.Read(reader, binary.LittleEndian, &offset) != nil {
+			return 0, false
+		}
+		index[h] = offset
+	}
+
+	overlap := make(map[string]int64)
+	if binary.Read(reader, binary.LittleEndian, &count) != nil {
+		return 0, false
+	}
+	for i := uint64(0); i < count; i++ {
+		var keyLen uint32
+		if binary.Read(reader, binary.LittleEndian, &keyLen) != nil || keyLen > maxKeyLength {
+			return 0, false
+		}
+		key := make([]byte, keyLen)
+		var offset int64
+		if _, err := io.ReadFull(reader, key); err != nil || binary.Read(reader, binary.LittleEndian, &offset) != nil {
+			return 0, false
+		}
+		overlap[string(key)] = offset
+	}
+
+	db.index = index
+	db.overlap = overlap
+	db.garbage = garbage
+	return size, true
+}
+
+// encodeRecord 编码一条日志记录
+func encodeRecord(buf *bytes.Buffer, key string, value []byte, flags byte) {
+	header := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(key)))
+	binary.LittleEndian.PutUint32(header[8:12], uint32(len(value)))
+	header[12] = flags
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write([]byte(key))
+	crc.Write(value)
+	binary.LittleEndian.PutUint32(header[0:4], crc.Sum32())
+
+	buf.Write(header)
+	buf.WriteString(key)
+	buf.Write(value)
+}
+
+// hashKey 计算键的64位哈希，测试中可替换以构造哈希冲突
+var hashKey = fnv64a
+
+// fnv64a 计算键的 FNV-1a 64位哈希
+func fnv64a(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
@@ -0,0 +1,415 @@
+package kvstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openTest 打开测试存储，测试结束时关闭
+func openTest(t *testing.T, dir string) *DB {
+	t.Helper()
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// expectValue 确认键的值
+func expectValue(t *testing.T, db *DB, key, want string) {
+	t.Helper()
+
+	got, err := db.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	if string(got) != want {
+		t.Errorf("Get(%q) = %q, 应为 %q", key, got, want)
+	}
+}
+
+// expectMissing 确认键不存在
+func expectMissing(t *testing.T, db *DB, key string) {
+	t.Helper()
+
+	if _, err := db.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(%q) 错误 = %v, 应为 ErrNotFound", key, err)
+	}
+	if db.Has(key) {
+		t.Errorf("Has(%q) = true, 应为 false", key)
+	}
+}
+
+// crash 不写快照直接关闭日志，模拟进程异常退出
+func crash(db *DB) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.log.Close()
+	db.log = nil
+}
+
+// fileSize 返回文件大小
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+// fill 写入一组测试数据：覆盖 b、删除 c
+func fill(t *testing.T, db *DB) {
+	t.Helper()
+
+	if err := db.PutBatch(map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("b", []byte("22")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectFilled 确认 fill 写入的数据
+func expectFilled(t *testing.T, db *DB) {
+	t.Helper()
+
+	expectValue(t, db, "a", "1")
+	expectValue(t, db, "b", "22")
+	expectMissing(t, db, "c")
+	if n := db.Len(); n != 2 {
+		t.Errorf("Len() = %d, 应为 2", n)
+	}
+}
+
+func TestReopenWithHint(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, db)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, hintFileName)); err != nil {
+		t.Fatalf("正常关闭后应写出索引快照: %v", err)
+	}
+
+	db = openTest(t, dir)
+	expectFilled(t, db)
+
+	// 快照之后追加的记录在下次打开时重新扫描
+	if err := db.Put("d", []byte("4")); err != nil {
+		t.Fatal(err)
+	}
+	crash(db)
+
+	db = openTest(t, dir)
+	expectValue(t, db, "a", "1")
+	expectValue(t, db, "b", "22")
+	expectValue(t, db, "d", "4")
+	expectMissing(t, db, "c")
+}
+
+func TestReopenWithoutHint(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, db)
+	crash(db)
+
+	if _, err := os.Stat(filepath.Join(dir, hintFileName)); !os.IsNotExist(err) {
+		t.Fatalf("异常退出后不应有索引快照: %v", err)
+	}
+
+	db = openTest(t, dir)
+	expectFilled(t, db)
+}
+
+func TestStaleHintIgnored(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, db)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 快照记录的长度超过日志，说明日志被替换过，快照不可用
+	hint, err := os.ReadFile(filepath.Join(dir, hintFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, logFileName), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, hintFileName), hint, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTest(t, dir)
+	if n := db.Len(); n != 0 {
+		t.Errorf("Len() = %d, 过期快照不应被加载", n)
+	}
+	expectMissing(t, db, "a")
+}
+
+func TestTruncatedTail(t *testing.T) {
+	tests := []struct {
+		name string
+		tail func(record []byte) []byte
+	}{
+		{"半条记录", func(record []byte) []byte { return record[:len(record)/2] }},
+		{"只有记录头", func(record []byte) []byte { return record[:headerSize-1] }},
+		{"校验和错误", func(record []byte) []byte {
+			record[len(record)-1] ^= 0xff
+			return record
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			logPath := filepath.Join(dir, logFileName)
+
+			db, err := Open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fill(t, db)
+			crash(db)
+			valid := fileSize(t, logPath)
+
+			// 追加一条写了一半或损坏的记录
+			record := encodeTestRecord("torn", "value")
+			file, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := file.Write(tt.tail(record)); err != nil {
+				t.Fatal(err)
+			}
+			file.Close()
+
+			db, err = Open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectFilled(t, db)
+			expectMissing(t, db, "torn")
+			if size := fileSize(t, logPath); size != valid {
+				t.Errorf("日志大小 = %d, 应截断为 %d", size, valid)
+			}
+
+			// 截断后的追加写入从有效末尾开始，重新打开后可读
+			if err := db.Put("e", []byte("5")); err != nil {
+				t.Fatal(err)
+			}
+			crash(db)
+
+			db = openTest(t, dir)
+			expectValue(t, db, "a", "1")
+			expectValue(t, db, "b", "22")
+			expectValue(t, db, "e", "5")
+			expectMissing(t, db, "torn")
+		})
+	}
+}
+
+func TestCorruptLengthInTail(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, logFileName)
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(t, db)
+	crash(db)
+	valid := fileSize(t, logPath)
+
+	// 记录头声明约4GiB的值，文件中只有几个字节；按该长度分配内存会耗尽内存
+	header := encodeTestRecord("k", "v")[:headerSize]
+	binary.LittleEndian.PutUint32(header[8:12], 0xfffffff0)
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write(append(header, "kv"...)); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	db = openTest(t, dir)
+	expectFilled(t, db)
+	if size := fileSize(t, logPath); size != valid {
+		t.Errorf("日志大小 = %d, 应截断为 %d", size, valid)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, logFileName)
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 反复覆盖和删除，使失效记录超过一半
+	for round := 0; round < 10; round++ {
+		entries := make(map[string][]byte)
+		for i := 0; i < 20; i++ {
+			entries[fmt.Sprintf("key-%d", i)] = []byte(fmt.Sprintf("value-%d-%d", i, round))
+		}
+		if err := db.PutBatch(entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 10; i < 20; i++ {
+		if err := db.Delete(fmt.Sprintf("key-%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := fileSize(t, logPath)
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	after := fileSize(t, logPath)
+	if after >= before/2 {
+		t.Errorf("压缩后日志 %d 字节，压缩前 %d 字节", after, before)
+	}
+	if _, err := os.Stat(logPath + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("压缩临时文件应已删除: %v", err)
+	}
+
+	check := func(db *DB) {
+		t.Helper()
+		for i := 0; i < 10; i++ {
+			expectValue(t, db, fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d-9", i))
+		}
+		for i := 10; i < 20; i++ {
+			expectMissing(t, db, fmt.Sprintf("key-%d", i))
+		}
+		if n := db.Len(); n != 10 {
+			t.Errorf("Len() = %d, 应为 10", n)
+		}
+	}
+
+	// 通过快照打开
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(db)
+	if db.garbage != 0 {
+		t.Errorf("压缩后 garbage = %d, 应为 0", db.garbage)
+	}
+	crash(db)
+
+	// 不通过快照，重新扫描压缩后的日志
+	db = openTest(t, dir)
+	check(db)
+}
+
+func TestHashCollisions(t *testing.T) {
+	// 所有键哈希相同，全部依赖 overlap 区分
+	hashKey = func(string) uint64 { return 42 }
+	t.Cleanup(func() { hashKey = fnv64a })
+
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.PutBatch(map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, db, "a", "1")
+	expectValue(t, db, "b", "2")
+	expectValue(t, db, "c", "3")
+	expectMissing(t, db, "x")
+	if n := db.Len(); n != 3 {
+		t.Errorf("Len() = %d, 应为 3", n)
+	}
+
+	// 覆盖和删除冲突的键不影响其他键
+	if err := db.Put("b", []byte("22")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("d", []byte("4")); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(db *DB) {
+		t.Helper()
+		expectMissing(t, db, "a")
+		expectValue(t, db, "b", "22")
+		expectMissing(t, db, "c")
+		expectValue(t, db, "d", "4")
+		if n := db.Len(); n != 2 {
+			t.Errorf("Len() = %d, 应为 2", n)
+		}
+
+		seen := make(map[string]string)
+		if err := db.ForEach(func(key string, value []byte) error {
+			seen[key] = string(value)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if len(seen) != 2 || seen["b"] != "22" || seen["d"] != "4" {
+			t.Errorf("ForEach = %v", seen)
+		}
+	}
+	check(db)
+
+	// 关闭时压缩并写出包含 overlap 的快照
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(db)
+
+	// 不通过快照，扫描日志重建冲突索引
+	crash(db)
+	db = openTest(t, dir)
+	check(db)
+}
+
+// encodeTestRecord 编码一条完整记录
+func encodeTestRecord(key, value string) []byte {
+	var buf bytes.Buffer
+	encodeRecord(&buf, key, []byte(value), 0)
+	return buf.Bytes()
+}
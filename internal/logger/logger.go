@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/k12/submit-sitemap/pkg/types"
 )
 
 // Logger 日志记录器
@@ -181,6 +183,15 @@ func LogSubmitResult(site, platform string, success, failed int, urls []string)
 	}
 }
 
+// LogEndpointResults 记录多接口提交中失败的接口，批次整体成功时同样记录
+func LogEndpointResults(site, platform string, endpoints []types.EndpointResult) {
+	for _, r := range endpoints {
+		if r.Error != nil {
+			Warning("接口提交失败 - 网站: %s, 平台: %s, 错误: %v", site, platform, r.Error)
+		}
+	}
+}
+
 // LogSitemapParsed 记录sitemap解析结果
 func LogSitemapParsed(url string, count int) {
 	Info("Sitemap解析完成 - URL: %s, 找到 %d 个URL", url, count)
@@ -0,0 +1,313 @@
+// Package quota 每日提交配额台账
+//
+// 记录每个站点、每个平台每天已经提交的URL数量，多次运行共享同一天的配额。
+// 日期按平台配额重置所在的时区计算，例如百度在北京时间零点重置。
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	_ "time/tzdata" // 系统缺少时区数据库时仍能加载 Asia/Shanghai 等时区
+
+	"github.com/k12/submit-sitemap/internal/kvstore"
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// dateLayout 台账日期格式
+const dateLayout = "2006-01-02"
+
+// DefaultTimezones 各平台配额重置的默认时区，未列出的平台使用本地时区
+var DefaultTimezones = map[string]string{
+	"baidu":        "Asia/Shanghai",
+	"baidu_daily":  "Asia/Shanghai",
+	"baidu_update": "Asia/Shanghai",
+	"baidu_del":    "Asia/Shanghai",
+	"yandex":       "Europe/Moscow",
+}
+
+// Ledger 每日配额使用台账，每个站点一个存储，位于 <dataDir>/quota/<domain>.db
+type Ledger struct {
+	dataDir   string
+	locations map[string]*time.Location
+	dbs       map[string]*kvstore.DB
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// NewLedger 创建配额台账，timezones 为平台到IANA时区名的映射，覆盖 DefaultTimezones
+func NewLedger(dataDir string, timezones map[string]string) (*Ledger, error) {
+	locations := make(map[string]*time.Location)
+	for platform, name := range DefaultTimezones {
+		if _, ok := timezones[platform]; ok {
+			continue
+		}
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("加载时区 %s 失败: %w", name, err)
+		}
+		locations[platform] = location
+	}
+	for platform, name := range timezones {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("平台 %s 的配额时区 %q 无效: %w", platform, name, err)
+		}
+		locations[platform] = location
+	}
+
+	return &Ledger{
+		dataDir:   dataDir,
+		locations: locations,
+		dbs:       make(map[string]*kvstore.DB),
+		now:       time.Now,
+	}, nil
+}
+
+// Today 返回平台配额所在时区的当天日期
+func (l *Ledger) Today(platform string) string {
+	location, ok := l.locations[platform]
+	if !ok {
+		location = time.Local
+	}
+	return l.now().In(location).Format(dateLayout)
+}
+
+// Used 返回站点平台当天已使用的配额
+func (l *Ledger) Used(domain, platform string) (int, error) {
+	db, err := l.open(domain)
+	if err != nil {
+		return 0, err
+	}
+	return readCount(db, usageKey(platform, l.Today(platform)))
+}
+
+// Remaining 返回当天剩余可提交的数量，limit 为配置的每日配额，为0时表示不提交到该平台
+// 平台返回过剩余配额（如百度的 remain）时参考平台数据，但结果不超过 limit 减去当天已用量：
+// 当天返回过的取两者较小值；之前某天推算出了每日总配额的，用它（不超过 limit）减去当天已用量
+func (l *Ledger) Remaining(domain, platform string, limit int) (int, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+
+	used, err := l.Used(domain, platform)
+	if err != nil {
+		return 0, err
+	}
+
+	last, ok, err := l.LastRemain(domain, platform)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		if last.Date == l.Today(platform) {
+			return max(min(limit-used, last.Remain), 0), nil
+		}
+		if last.Allowance > 0 {
+			limit = min(limit, last.Allowance)
+		}
+	}
+
+	if used >= limit {
+		return 0, nil
+	}
+	return limit - used, nil
+}
+
+// Remain 平台最近一次返回的剩余配额
+type Remain struct {
+	Date      string `json:"date"`      // 返回时所在的配额日期
+	Remain    int    `json:"remain"`    // 当天剩余配额
+	Allowance int    `json:"allowance"` // 推算的每日总配额：当天已用量 + 剩余配额，0 表示未知
+}
+
+// LastRemain 读取平台最近一次返回的剩余配额
+func (l *Ledger) LastRemain(domain, platform string) (Remain, bool, error) {
+	db, err := l.open(domain)
+	if err != nil {
+		return Remain{}, false, err
+	}
+
+	return readRemain(db, platform)
+}
+
+// SetRemain 保存平台返回的剩余配额，应在当批成功数计入台账之后调用
+// 只有当天已有成功提交时才推算每日总配额：没有用量时的 remain（如首次提交即超额返回的0）
+// 可能只反映了其他途径的消耗，据此推算会把之后每天的配额都锁成0，此时沿用之前的推算值
+func (l *Ledger) SetRemain(domain, platform string, remain int) error {
+	db, err := l.open(domain)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	today := l.Today(platform)
+	used, err := readCount(db, usageKey(platform, today))
+	if err != nil {
+		return err
+	}
+
+	allowance := 0
+	if used > 0 {
+		allowance = used + max(remain, 0)
+	} else if last, ok, err := readRemain(db, platform); err != nil {
+		return err
+	} else if ok {
+		allowance = last.Allowance
+	}
+
+	data, err := json.Marshal(Remain{
+		Date:      today,
+		Remain:    remain,
+		Allowance: allowance,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化剩余配额失败: %w", err)
+	}
+	if err := db.Put(remainKey(platform), data); err != nil {
+		return fmt.Errorf("更新配额台账失败: %w", err)
+	}
+	return nil
+}
+
+// Add 将提交数量计入当天的配额
+func (l *Ledger) Add(domain, platform string, count int) error {
+	if count <= 0 {
+		return nil
+	}
+
+	db, err := l.open(domain)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := usageKey(platform, l.Today(platform))
+	used, err := readCount(db, key)
+	if err != nil {
+		return err
+	}
+	if err := db.Put(key, []byte(strconv.Itoa(used+count))); err != nil {
+		return fmt.Errorf("更新配额台账失败: %w", err)
+	}
+	return nil
+}
+
+// Recorder 返回每批提交完成后调用的回调：把成功数和平台返回的剩余配额计入台账，
+// 当天配额用完时返回 false 停止后续批次；台账读写失败时返回错误，同样停止，避免提交了却没有记账
+func (l *Ledger) Recorder(domain, platform string, limit int) func(batch []string, result types.SubmitResult) (bool, error) {
+	return func(_ []string, result types.SubmitResult) (bool, error) {
+		if err := l.Add(domain, platform, result.SuccessCount); err != nil {
+			return false, fmt.Errorf("%s 配额记账失败: %w", platform, err)
+		}
+		if result.RemainKnown {
+			if err := l.SetRemain(domain, platform, result.Remain); err != nil {
+				return false, fmt.Errorf("%s 剩余配额记账失败: %w", platform, err)
+			}
+		}
+		remaining, err := l.Remaining(domain, platform, limit)
+		if err != nil {
+			return false, fmt.Errorf("%s 读取剩余配额失败: %w", platform, err)
+		}
+		return remaining > 0, nil
+	}
+}
+
+// Close 关闭全部存储
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var firstErr error
+	for domain, db := range l.dbs {
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(l.dbs, domain)
+	}
+	return firstErr
+}
+
+// Reset 删除站点的配额台账
+func (l *Ledger) Reset(domain string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if db, ok := l.dbs[domain]; ok {
+		db.Close()
+		delete(l.dbs, domain)
+	}
+	if err := os.RemoveAll(filepath.Join(l.dataDir, "quota", domain+".db")); err != nil {
+		return fmt.Errorf("删除配额台账失败: %w", err)
+	}
+	return nil
+}
+
+// open 打开站点的台账存储
+func (l *Ledger) open(domain string) (*kvstore.DB, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if db, ok := l.dbs[domain]; ok {
+		return db, nil
+	}
+
+	db, err := kvstore.Open(filepath.Join(l.dataDir, "quota", domain+".db"))
+	if err != nil {
+		return nil, fmt.Errorf("打开配额台账失败: %w", err)
+	}
+	l.dbs[domain] = db
+	return db, nil
+}
+
+// readCount 读取计数，不存在时为0
+func readCount(db *kvstore.DB, key string) (int, error) {
+	data, err := db.Get(key)
+	if errors.Is(err, kvstore.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取配额台账失败: %w", err)
+	}
+	count, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("配额台账数据无效: %w", err)
+	}
+	return count, nil
+}
+
+// readRemain 读取平台最近一次返回的剩余配额
+func readRemain(db *kvstore.DB, platform string) (Remain, bool, error) {
+	data, err := db.Get(remainKey(platform))
+	if errors.Is(err, kvstore.ErrNotFound) {
+		return Remain{}, false, nil
+	}
+	if err != nil {
+		return Remain{}, false, fmt.Errorf("读取配额台账失败: %w", err)
+	}
+
+	var remain Remain
+	if err := json.Unmarshal(data, &remain); err != nil {
+		return Remain{}, false, fmt.Errorf("配额台账数据无效: %w", err)
+	}
+	return remain, true, nil
+}
+
+// usageKey 平台某天用量的键
+func usageKey(platform, date string) string {
+	return platform + "/" + date
+}
+
+// remainKey 平台最近一次返回的剩余配额的键
+func remainKey(platform string) string {
+	return platform + "/remain"
+}
@@ -0,0 +1,354 @@
+// Package retryqueue 提交失败URL的持久化重试队列
+//
+// 每个站点的每个平台一个队列，记录失败原因和尝试次数。临时性失败按指数退避等待后
+// 在下次运行时优先重试，超过最大尝试次数或遇到永久性错误（如百度 not_valid）时
+// 移入死信列表，不再自动重试。
+package retryqueue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/k12/submit-sitemap/internal/kvstore"
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// Entry 队列中的失败URL
+type Entry struct {
+	URL           string    `json:"-"`
+	Reason        string    `json:"reason"`                 // 最近一次失败原因
+	Attempts      int       `json:"attempts"`               // 累计失败次数
+	FirstFailedAt time.Time `json:"first_failed_at"`        // 首次失败时间
+	LastFailedAt  time.Time `json:"last_failed_at"`         // 最近一次失败时间
+	NextRetryAt   time.Time `json:"next_retry_at,omitzero"` // 下次可重试时间
+	Permanent     bool      `json:"permanent,omitempty"`    // 永久性错误，重试也不会成功
+	Dead          bool      `json:"dead,omitempty"`         // 已移入死信列表
+}
+
+// Queue 重试队列，存储位于 <dataDir>/retry/<domain>/<platform>.db
+type Queue struct {
+	dataDir     string
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	dbs         map[string]*kvstore.DB
+	now         func() time.Time
+	mu          sync.Mutex
+}
+
+// NewQueue 创建重试队列
+func NewQueue(dataDir string, settings types.RetrySettings) *Queue {
+	return &Queue{
+		dataDir:     dataDir,
+		maxAttempts: settings.MaxAttempts,
+		baseDelay:   time.Duration(settings.BaseDelayMinutes) * time.Minute,
+		maxDelay:    time.Duration(settings.MaxDelayHours) * time.Hour,
+		dbs:         make(map[string]*kvstore.DB),
+		now:         time.Now,
+	}
+}
+
+// Select 将到期的重试URL排在 selected 前面，并按 limit 截取（limit 不大于0时不限制）
+// urls 为当前全部URL，已从中消失的队列URL直接移出队列；
+// 尚未到重试时间或已进入死信列表的URL即使出现在 selected 中也会被跳过
+func (q *Queue) Select(domain, platform string, urls, selected []types.SitemapURL, limit int) ([]types.SitemapURL, error) {
+	entries, err := q.entries(domain, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]types.SitemapURL, len(urls))
+	for _, u := range urls {
+		current[u.Loc] = u
+	}
+
+	now := q.now()
+	var due []Entry
+	queued := make(map[string]bool, len(entries))
+	var gone []string
+	for _, entry := range entries {
+		if _, ok := current[entry.URL]; !ok && !entry.Dead {
+			gone = append(gone, entry.URL)
+			continue
+		}
+		queued[entry.URL] = true
+		if !entry.Dead && !entry.NextRetryAt.After(now) {
+			due = append(due, entry)
+		}
+	}
+	if err := q.remove(domain, platform, gone); err != nil {
+		return nil, err
+	}
+
+	// 等待最久的优先
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextRetryAt.Before(due[j].NextRetryAt)
+	})
+
+	result := make([]types.SitemapURL, 0, len(due)+len(selected))
+	for _, entry := range due {
+		result = append(result, current[entry.URL])
+	}
+	for _, u := range selected {
+		if !queued[u.Loc] {
+			result = append(result, u)
+		}
+	}
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// Record 根据一批提交的结果更新队列：成功的URL移出队列，失败的URL累加次数并计算下次重试时间
+// 因当天配额用完（types.ErrOverQuota）而未提交的URL不累加次数，下次运行时直接重试
+func (q *Queue) Record(domain, platform string, batch []string, result types.SubmitResult) error {
+	db, err := q.open(domain, platform)
+	if err != nil {
+		return err
+	}
+
+	failed := make(map[string]bool, len(result.FailedURLs))
+	for _, u := range result.FailedURLs {
+		failed[u] = true
+	}
+
+	var succeeded []string
+	for _, u := range batch {
+		if !failed[u] {
+			succeeded = append(succeeded, u)
+		}
+	}
+	if err := q.remove(domain, platform, succeeded); err != nil {
+		return err
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	reason := "提交失败"
+	if result.Error != nil {
+		reason = result.Error.Error()
+	}
+
+	overQuota := errors.Is(result.Error, types.ErrOverQuota)
+
+	now := q.now()
+	updates := make(map[string][]byte, len(failed))
+	for u := range failed {
+		entry, err := q.get(db, u)
+		if err != nil {
+			return err
+		}
+		if entry.FirstFailedAt.IsZero() {
+			entry.FirstFailedAt = now
+		}
+		entry.LastFailedAt = now
+		entry.Reason = reason
+		entry.NextRetryAt = time.Time{}
+
+		permanent, isPermanent := result.Permanent[u]
+		if overQuota && !isPermanent {
+			// 配额重置后即可提交，不是URL本身的问题
+			entry.NextRetryAt = now
+		} else {
+			entry.Attempts++
+			if isPermanent {
+				entry.Reason = permanent
+				entry.Permanent = true
+				entry.Dead = true
+			} else if q.maxAttempts > 0 && entry.Attempts >= q.maxAttempts {
+				entry.Dead = true
+			} else {
+				entry.NextRetryAt = now.Add(q.backoff(entry.Attempts))
+			}
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("序列化重试记录失败: %w", err)
+		}
+		updates[u] = data
+	}
+
+	if err := db.PutBatch(updates); err != nil {
+		return fmt.Errorf("更新重试队列失败: %w", err)
+	}
+	return nil
+}
+
+// Recorder 返回每批提交完成后调用的回调，把结果记入重试队列；记录失败时返回错误并停止后续批次
+func (q *Queue) Recorder(domain, platform string) func(batch []string, result types.SubmitResult) (bool, error) {
+	return func(batch []string, result types.SubmitResult) (bool, error) {
+		if err := q.Record(domain, platform, batch, result); err != nil {
+			return false, fmt.Errorf("%s 重试队列记录失败: %w", platform, err)
+		}
+		return true, nil
+	}
+}
+
+// Pending 返回等待重试的URL（不含死信）
+func (q *Queue) Pending(domain, platform string) ([]Entry, error) {
+	entries, err := q.entries(domain, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Entry
+	for _, entry := range entries {
+		if !entry.Dead {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, nil
+}
+
+// DeadLetters 返回死信列表中的URL
+func (q *Queue) DeadLetters(domain, platform string) ([]Entry, error) {
+	entries, err := q.entries(domain, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	var dead []Entry
+	for _, entry := range entries {
+		if entry.Dead {
+			dead = append(dead, entry)
+		}
+	}
+	return dead, nil
+}
+
+// Reset 删除站点的全部重试记录
+func (q *Queue) Reset(domain string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	prefix := domain + "/"
+	for key, db := range q.dbs {
+		if len(key) > len(prefix) && key[:len(prefix)] == prefix {
+			db.Close()
+			delete(q.dbs, key)
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(q.dataDir, "retry", domain)); err != nil {
+		return fmt.Errorf("删除重试队列失败: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭全部存储
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var firstErr error
+	for key, db := range q.dbs {
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(q.dbs, key)
+	}
+	return firstErr
+}
+
+// backoff 第 attempts 次失败后的等待时间：基础时间逐次翻倍，不超过上限
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.baseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if q.maxDelay > 0 && delay >= q.maxDelay {
+			return q.maxDelay
+		}
+	}
+	return delay
+}
+
+// entries 读取队列中的全部记录
+func (q *Queue) entries(domain, platform string) ([]Entry, error) {
+	db, err := q.open(domain, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	err = db.ForEach(func(url string, data []byte) error {
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("解析重试记录失败: %w", err)
+		}
+		entry.URL = url
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// get 读取单条记录，不存在时返回零值
+func (q *Queue) get(db *kvstore.DB, url string) (Entry, error) {
+	data, err := db.Get(url)
+	if errors.Is(err, kvstore.ErrNotFound) {
+		return Entry{URL: url}, nil
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("读取重试记录失败: %w", err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, fmt.Errorf("解析重试记录失败: %w", err)
+	}
+	entry.URL = url
+	return entry, nil
+}
+
+// remove 将URL移出队列
+func (q *Queue) remove(domain, platform string, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	db, err := q.open(domain, platform)
+	if err != nil {
+		return err
+	}
+	for _, u := range urls {
+		if !db.Has(u) {
+			continue
+		}
+		if err := db.Delete(u); err != nil {
+			return fmt.Errorf("更新重试队列失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// open 打开站点平台对应的队列存储
+func (q *Queue) open(domain, platform string) (*kvstore.DB, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := domain + "/" + platform
+	if db, ok := q.dbs[key]; ok {
+		return db, nil
+	}
+
+	db, err := kvstore.Open(filepath.Join(q.dataDir, "retry", domain, platform+".db"))
+	if err != nil {
+		return nil, fmt.Errorf("打开重试队列失败: %w", err)
+	}
+	q.dbs[key] = db
+	return db, nil
+}
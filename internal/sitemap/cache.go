@@ -0,0 +1,180 @@
+package sitemap
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheEntry 缓存元数据，正文单独存放在 .body 文件中
+type CacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// Cache sitemap磁盘缓存
+// 以sitemap URL为键，保存解压后的正文以及 ETag/Last-Modified，用于条件请求
+type Cache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewCache 创建缓存，文件存放在 <dataDir>/sitemap_cache 下
+func NewCache(dataDir string, cacheHours int) *Cache {
+	return &Cache{
+		dir: filepath.Join(dataDir, "sitemap_cache"),
+		ttl: time.Duration(cacheHours) * time.Hour,
+	}
+}
+
+// Lookup 读取缓存元数据，不存在或损坏时返回 nil
+func (c *Cache) Lookup(url string) (*CacheEntry, error) {
+	metaPath, bodyPath := c.paths(url)
+
+	metaData, err := os.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取缓存失败: %w", err)
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(metaData, &entry); err != nil {
+		// 元数据损坏时当作未缓存处理
+		return nil, nil
+	}
+
+	if _, err := os.Stat(bodyPath); err != nil {
+		return nil, nil
+	}
+
+	return &entry, nil
+}
+
+// Open 打开缓存的sitemap正文
+func (c *Cache) Open(url string) (*os.File, error) {
+	_, bodyPath := c.paths(url)
+
+	file, err := os.Open(bodyPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取缓存失败: %w", err)
+	}
+	return file, nil
+}
+
+// Fresh 判断缓存条目是否仍在有效期内
+func (c *Cache) Fresh(entry *CacheEntry) bool {
+	return entry != nil && time.Since(entry.FetchedAt) < c.ttl
+}
+
+// Create 创建缓存写入器，正文边下载边写入临时文件，Commit 后才替换旧缓存
+func (c *Cache) Create(entry CacheEntry) (*CacheWriter, error) {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+
+	_, bodyPath := c.paths(entry.URL)
+	tmp, err := os.CreateTemp(c.dir, filepath.Base(bodyPath)+".tmp*")
+	if err != nil {
+		return nil, fmt.Errorf("创建缓存文件失败: %w", err)
+	}
+
+	return &CacheWriter{cache: c, entry: entry, file: tmp}, nil
+}
+
+// CacheWriter 流式写入单个缓存条目
+type CacheWriter struct {
+	cache *Cache
+	entry CacheEntry
+	file  *os.File
+	err   error
+}
+
+// Write 写入正文数据，出错后后续写入被忽略，Commit 时返回错误
+func (w *CacheWriter) Write(b []byte) (int, error) {
+	if w.err == nil {
+		_, w.err = w.file.Write(b)
+	}
+	return len(b), nil
+}
+
+// Commit 完成写入，先替换正文再写元数据，保证元数据存在时正文完整
+func (w *CacheWriter) Commit() error {
+	tmpName := w.file.Name()
+	if err := w.file.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if w.err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("写入缓存失败: %w", w.err)
+	}
+
+	metaPath, bodyPath := w.cache.paths(w.entry.URL)
+	if err := os.Rename(tmpName, bodyPath); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("写入缓存失败: %w", err)
+	}
+
+	metaData, err := json.Marshal(w.entry)
+	if err != nil {
+		return fmt.Errorf("序列化缓存失败: %w", err)
+	}
+	if err := writeFileAtomic(metaPath, metaData); err != nil {
+		return fmt.Errorf("写入缓存失败: %w", err)
+	}
+
+	return nil
+}
+
+// Abort 放弃写入，删除临时文件
+func (w *CacheWriter) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// Touch 在收到304后刷新缓存时间
+func (c *Cache) Touch(entry *CacheEntry) error {
+	metaPath, _ := c.paths(entry.URL)
+
+	entry.FetchedAt = time.Now()
+	metaData, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化缓存失败: %w", err)
+	}
+	return writeFileAtomic(metaPath, metaData)
+}
+
+// paths 返回缓存元数据和正文的文件路径
+func (c *Cache) paths(url string) (string, string) {
+	sum := sha1.Sum([]byte(url))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, key+".json"), filepath.Join(c.dir, key+".body")
+}
+
+// writeFileAtomic 写入临时文件后重命名，避免留下半截文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,139 @@
+package sitemap
+
+import (
+	"strings"
+
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// Image image:image 图片扩展
+type Image struct {
+	Loc string `xml:"loc"`
+}
+
+// Video video:video 视频扩展
+type Video struct {
+	Title           string `xml:"title"`
+	ThumbnailLoc    string `xml:"thumbnail_loc"`
+	ContentLoc      string `xml:"content_loc"`
+	PlayerLoc       string `xml:"player_loc"`
+	PublicationDate string `xml:"publication_date"`
+}
+
+// News news:news 新闻扩展
+type News struct {
+	Publication struct {
+		Name     string `xml:"name"`
+		Language string `xml:"language"`
+	} `xml:"publication"`
+	PublicationDate string `xml:"publication_date"`
+	Title           string `xml:"title"`
+}
+
+// Link xhtml:link 多语言链接
+type Link struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// convertExtensions 将扩展元素转换到 types.SitemapURL
+func convertExtensions(u URL, result *types.SitemapURL) {
+	for _, link := range u.Links {
+		href := strings.TrimSpace(link.Href)
+		if !strings.EqualFold(link.Rel, "alternate") || href == "" {
+			continue
+		}
+		result.Alternates = append(result.Alternates, types.AlternateURL{
+			Hreflang: strings.TrimSpace(link.Hreflang),
+			Href:     href,
+		})
+	}
+
+	for _, img := range u.Images {
+		if loc := strings.TrimSpace(img.Loc); loc != "" {
+			result.Images = append(result.Images, loc)
+		}
+	}
+
+	for _, v := range u.Videos {
+		result.Videos = append(result.Videos, types.SitemapVideo{
+			Title:           strings.TrimSpace(v.Title),
+			ThumbnailLoc:    strings.TrimSpace(v.ThumbnailLoc),
+			ContentLoc:      strings.TrimSpace(v.ContentLoc),
+			PlayerLoc:       strings.TrimSpace(v.PlayerLoc),
+			PublicationDate: strings.TrimSpace(v.PublicationDate),
+		})
+	}
+
+	if u.News != nil {
+		result.News = &types.SitemapNews{
+			PublicationName: strings.TrimSpace(u.News.Publication.Name),
+			Language:        strings.TrimSpace(u.News.Publication.Language),
+			PublicationDate: strings.TrimSpace(u.News.PublicationDate),
+			Title:           strings.TrimSpace(u.News.Title),
+		}
+		// 新闻sitemap通常没有lastmod，使用发布时间代替
+		if result.LastMod == "" {
+			result.LastMod = result.News.PublicationDate
+		}
+	}
+}
+
+// alternateURLs 将hreflang多语言版本展开为独立的URL，沿用规范URL的 lastmod、changefreq、priority 和权重
+// 展开后的URL与普通URL一样参与历史过滤、配额截断和 lastmod 记录
+func alternateURLs(u types.SitemapURL) []types.SitemapURL {
+	var result []types.SitemapURL
+	for _, alt := range u.Alternates {
+		if alt.Href == "" || alt.Href == u.Loc {
+			continue
+		}
+		result = append(result, types.SitemapURL{
+			Loc:        alt.Href,
+			LastMod:    u.LastMod,
+			ChangeFreq: u.ChangeFreq,
+			Priority:   u.Priority,
+			Weight:     u.Weight,
+		})
+	}
+	return result
+}
+
+// ExpandAlternates 在每个URL之后插入其hreflang多语言版本，按出现顺序去重
+// 已作为规范URL出现的地址保留其自身的条目，用于在 SelectForSubmit 之前展开 Parse 的结果
+func ExpandAlternates(urls []types.SitemapURL) []types.SitemapURL {
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		seen[u.Loc] = true
+	}
+
+	result := make([]types.SitemapURL, 0, len(urls))
+	for _, u := range urls {
+		result = append(result, u)
+		for _, alt := range alternateURLs(u) {
+			if seen[alt.Loc] {
+				continue
+			}
+			seen[alt.Loc] = true
+			result = append(result, alt)
+		}
+	}
+	return result
+}
+
+// ExtractLocs 提取待提交的URL列表，按出现顺序去重
+// hreflang多语言版本需要在选择之前通过 ExpandAlternates（或 submit_alternates）展开
+func ExtractLocs(urls []types.SitemapURL) []string {
+	seen := make(map[string]bool, len(urls))
+	result := make([]string, 0, len(urls))
+
+	for _, u := range urls {
+		if u.Loc == "" || seen[u.Loc] {
+			continue
+		}
+		seen[u.Loc] = true
+		result = append(result, u.Loc)
+	}
+
+	return result
+}
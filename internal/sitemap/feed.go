@@ -0,0 +1,141 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// kindNames 文档类型的显示名称
+var kindNames = map[string]string{
+	kindURLSet:       "URLSet",
+	kindSitemapIndex: "Sitemap Index",
+	kindRSS:          "RSS",
+	kindAtom:         "Atom",
+	kindText:         "纯文本 Sitemap",
+}
+
+// rssItem RSS 2.0 条目
+type rssItem struct {
+	Links   []string `xml:"link"` // 同时可能包含 atom:link，取第一个非空值
+	GUID    string   `xml:"guid"`
+	PubDate string   `xml:"pubDate"`
+	Date    string   `xml:"date"` // dc:date
+}
+
+// toURL 转换为sitemap URL，lastmod取pubDate
+func (i rssItem) toURL() (URL, bool) {
+	loc := ""
+	for _, link := range i.Links {
+		if link = strings.TrimSpace(link); link != "" {
+			loc = link
+			break
+		}
+	}
+	// 没有link时，永久链接形式的guid也可作为地址
+	if loc == "" && isHTTPURL(strings.TrimSpace(i.GUID)) {
+		loc = strings.TrimSpace(i.GUID)
+	}
+	if loc == "" {
+		return URL{}, false
+	}
+
+	lastMod := i.PubDate
+	if strings.TrimSpace(lastMod) == "" {
+		lastMod = i.Date
+	}
+	return URL{Loc: loc, LastMod: normalizeFeedDate(lastMod)}, true
+}
+
+// atomEntry Atom 条目
+type atomEntry struct {
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	Updated   string `xml:"updated"`
+	Published string `xml:"published"`
+}
+
+// toURL 转换为sitemap URL，地址取 rel="alternate"（或未指定rel）的链接，lastmod取updated
+func (e atomEntry) toURL() (URL, bool) {
+	loc := ""
+	for _, link := range e.Links {
+		rel := strings.TrimSpace(link.Rel)
+		if rel == "" || rel == "alternate" {
+			loc = strings.TrimSpace(link.Href)
+			break
+		}
+	}
+	if loc == "" {
+		return URL{}, false
+	}
+
+	lastMod := e.Updated
+	if strings.TrimSpace(lastMod) == "" {
+		lastMod = e.Published
+	}
+	return URL{Loc: loc, LastMod: normalizeFeedDate(lastMod)}, true
+}
+
+// feedDateLayouts RSS/Atom中常见的日期格式
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	time.RFC3339Nano,
+}
+
+// normalizeFeedDate 将RSS/Atom日期转换为sitemap使用的W3C日期格式，无法识别时原样返回
+func normalizeFeedDate(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return value
+}
+
+// looksLikeXML 跳过BOM和空白后，以 '<' 开头的内容视为XML
+func looksLikeXML(r *bufio.Reader) bool {
+	head, _ := r.Peek(512)
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	head = bytes.TrimLeft(head, " \t\r\n")
+	return len(head) > 0 && head[0] == '<'
+}
+
+// decodeText 解析纯文本sitemap，每行一个URL，非http(s)行被忽略
+func decodeText(r io.Reader, onURL func(URL) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if !isHTTPURL(line) {
+			continue
+		}
+		if err := onURL(URL{Loc: line}); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("解析纯文本sitemap失败: %w", err)
+	}
+	return nil
+}
+
+// isHTTPURL 判断是否为http(s)地址
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
@@ -1,14 +1,15 @@
 package sitemap
 
 import (
-	"compress/gzip"
+	"context"
 	"encoding/xml"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/k12/submit-sitemap/internal/httpretry"
 	"github.com/k12/submit-sitemap/pkg/types"
 )
 
@@ -19,11 +20,16 @@ type URLSet struct {
 }
 
 // URL sitemap中的URL结构
+// 扩展元素按本地名匹配，image:/video:/news:/xhtml: 前缀对应的命名空间均可识别
 type URL struct {
-	Loc        string `xml:"loc"`
-	LastMod    string `xml:"lastmod"`
-	ChangeFreq string `xml:"changefreq"`
-	Priority   string `xml:"priority"`
+	Loc        string  `xml:"loc"`
+	LastMod    string  `xml:"lastmod"`
+	ChangeFreq string  `xml:"changefreq"`
+	Priority   string  `xml:"priority"`
+	Images     []Image `xml:"image"`
+	Videos     []Video `xml:"video"`
+	News       *News   `xml:"news"`
+	Links      []Link  `xml:"link"`
 }
 
 // SitemapIndex sitemap索引XML结构
@@ -38,146 +44,358 @@ type Sitemap struct {
 	LastMod string `xml:"lastmod"`
 }
 
+const (
+	// defaultConcurrent 默认的子sitemap并发下载数，与 settings.concurrent 默认值一致
+	defaultConcurrent = 3
+	// defaultMaxDepth 默认的sitemap索引最大嵌套层数，与 settings.max_sitemap_depth 默认值一致
+	defaultMaxDepth = 5
+)
+
+// CycleError sitemap索引循环引用
+type CycleError struct {
+	Path []string // 从入口sitemap到重复出现的sitemap的完整路径
+}
+
+// Error 实现error接口
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("检测到sitemap循环引用: %s", strings.Join(e.Path, " -> "))
+}
+
+// DepthError sitemap索引嵌套层数超过限制
+type DepthError struct {
+	Path     []string // 从入口sitemap到超限sitemap的路径
+	MaxDepth int
+}
+
+// Error 实现error接口
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("sitemap索引嵌套超过 %d 层: %s", e.MaxDepth, strings.Join(e.Path, " -> "))
+}
+
+// ChildError 子sitemap解析失败信息
+type ChildError struct {
+	URL string
+	Err error
+}
+
+// Error 实现error接口
+func (e ChildError) Error() string {
+	return fmt.Sprintf("解析子sitemap失败 (%s): %v", e.URL, e.Err)
+}
+
+// Unwrap 返回原始错误
+func (e ChildError) Unwrap() error {
+	return e.Err
+}
+
 // Parser sitemap解析器
 type Parser struct {
-	client  *http.Client
-	timeout time.Duration
-	verbose bool // 是否输出详细日志
+	client     *http.Client
+	timeout    time.Duration
+	verbose    bool          // 是否输出详细日志
+	cache      *Cache        // sitemap磁盘缓存，为nil时不缓存
+	refresh    bool          // 忽略缓存强制重新下载
+	concurrent int           // 子sitemap并发下载数
+	sem        chan struct{} // 限制同时进行的下载数
+	maxDepth   int           // sitemap索引最大嵌套层数
+
+	mu          sync.Mutex
+	childErrors []ChildError    // 最近一次Parse中失败的子sitemap
+	visited     map[string]bool // 最近一次Parse中已处理的sitemap
 }
 
 // NewParser 创建新的解析器
 func NewParser(timeout int) *Parser {
-	return &Parser{
-		client: &http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
-		},
-		timeout: time.Duration(timeout) * time.Second,
-		verbose: false,
-	}
+	return NewParserWithVerbose(timeout, false)
 }
 
 // NewParserWithVerbose 创建带详细日志的解析器
 func NewParserWithVerbose(timeout int, verbose bool) *Parser {
 	return &Parser{
-		client: &http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
-		},
-		timeout: time.Duration(timeout) * time.Second,
-		verbose: verbose,
+		client:     httpretry.NewClient(time.Duration(timeout) * time.Second),
+		timeout:    time.Duration(timeout) * time.Second,
+		verbose:    verbose,
+		concurrent: defaultConcurrent,
+		sem:        make(chan struct{}, defaultConcurrent),
+		maxDepth:   defaultMaxDepth,
 	}
 }
 
-// Parse 解析sitemap URL
-func (p *Parser) Parse(sitemapURL string) ([]types.SitemapURL, error) {
-	if p.verbose {
-		fmt.Printf("📥 正在获取: %s\n", sitemapURL)
+// SetMaxDepth 设置sitemap索引最大嵌套层数（对应 settings.max_sitemap_depth）
+func (p *Parser) SetMaxDepth(n int) {
+	if n <= 0 {
+		n = defaultMaxDepth
 	}
+	p.maxDepth = n
+}
 
-	// 下载sitemap内容
-	content, err := p.fetch(sitemapURL)
-	if err != nil {
-		return nil, fmt.Errorf("获取sitemap失败: %w", err)
+// SetConcurrency 设置子sitemap并发下载数（对应 settings.concurrent）
+func (p *Parser) SetConcurrency(n int) {
+	if n <= 0 {
+		n = 1
 	}
+	p.concurrent = n
+	p.sem = make(chan struct{}, n)
+}
 
-	// 尝试解析为sitemap索引
-	var sitemapIndex SitemapIndex
-	if err := xml.Unmarshal(content, &sitemapIndex); err == nil && len(sitemapIndex.Sitemaps) > 0 {
-		if p.verbose {
-			fmt.Printf("✓ 识别为 Sitemap Index，包含 %d 个子 sitemap\n", len(sitemapIndex.Sitemaps))
-		}
-		return p.parseIndex(sitemapIndex)
-	}
+// ChildErrors 返回最近一次Parse中解析失败的子sitemap
+// 子sitemap失败不会中断整个索引的解析，调用方可据此记录警告
+func (p *Parser) ChildErrors() []ChildError {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	// 解析为普通sitemap
-	var urlset URLSet
-	if err := xml.Unmarshal(content, &urlset); err != nil {
-		return nil, fmt.Errorf("解析sitemap失败: %w", err)
-	}
+	return append([]ChildError(nil), p.childErrors...)
+}
 
-	if p.verbose {
-		fmt.Printf("✓ 识别为 URLSet，包含 %d 个URL\n", len(urlset.URLs))
+// EnableCache 启用sitemap磁盘缓存，缓存有效期由 sitemap_cache_hours 决定
+func (p *Parser) EnableCache(dataDir string, cacheHours int) {
+	if cacheHours <= 0 {
+		p.cache = nil
+		return
 	}
+	p.cache = NewCache(dataDir, cacheHours)
+}
 
-	return p.convertURLs(urlset.URLs), nil
+// SetRefresh 设置是否忽略缓存强制重新下载
+func (p *Parser) SetRefresh(refresh bool) {
+	p.refresh = refresh
 }
 
-// parseIndex 解析sitemap索引
-func (p *Parser) parseIndex(index SitemapIndex) ([]types.SitemapURL, error) {
+// Parse 解析sitemap URL
+func (p *Parser) Parse(sitemapURL string) ([]types.SitemapURL, error) {
+	return p.ParseContext(context.Background(), sitemapURL)
+}
+
+// ParseContext 解析sitemap URL，ctx取消时停止正在进行的下载
+func (p *Parser) ParseContext(ctx context.Context, sitemapURL string) ([]types.SitemapURL, error) {
 	var allURLs []types.SitemapURL
+	err := p.ParseStream(ctx, sitemapURL, func(u types.SitemapURL) error {
+		allURLs = append(allURLs, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allURLs, nil
+}
 
-	if p.verbose {
-		fmt.Printf("\n🔄 开始递归解析 %d 个子 sitemap...\n", len(index.Sitemaps))
+// ParseStream 流式解析sitemap URL，每解析出一个URL就调用一次fn
+// 单个sitemap边下载边解码，不会整体读入内存；fn返回错误时停止解析
+// 只接受 http(s) 地址，本地文件通过 file 来源（ParseSources）读取
+func (p *Parser) ParseStream(ctx context.Context, sitemapURL string, fn func(types.SitemapURL) error) error {
+	p.reset()
+	return p.parse(ctx, sitemapURL, nil, false, fn)
+}
+
+// reset 清空上一次解析记录的失败信息和已访问集合
+func (p *Parser) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.childErrors = nil
+	p.visited = make(map[string]bool)
+}
+
+// parse 下载并解析单个sitemap，遇到索引时递归解析子sitemap
+// parents 为从入口到当前sitemap的上级索引路径，用于检测循环引用和限制嵌套层数
+// local 为 true 时允许读取本地文件，只有配置为 file 的来源本身才会这样调用，
+// 子sitemap一律按远程地址处理，防止远程索引引用本机文件
+func (p *Parser) parse(ctx context.Context, sitemapURL string, parents []string, local bool, emit func(types.SitemapURL) error) error {
+	path := append(append([]string(nil), parents...), sitemapURL)
+	for _, parent := range parents {
+		if parent == sitemapURL {
+			return &CycleError{Path: path}
+		}
+	}
+	if len(parents) > p.maxDepth {
+		return &DepthError{Path: path, MaxDepth: p.maxDepth}
 	}
 
-	for i, sitemap := range index.Sitemaps {
+	// 多个索引引用同一个sitemap时只解析一次
+	p.mu.Lock()
+	seen := p.visited[sitemapURL]
+	p.visited[sitemapURL] = true
+	p.mu.Unlock()
+	if seen {
 		if p.verbose {
-			fmt.Printf("\n[%d/%d] ", i+1, len(index.Sitemaps))
+			fmt.Printf("↪ 已解析过，跳过: %s\n", sitemapURL)
 		}
+		return nil
+	}
 
-		urls, err := p.Parse(sitemap.Loc)
-		if err != nil {
-			// 记录错误但继续处理其他sitemap
-			fmt.Printf("⚠️  警告: 解析子sitemap失败 (%s): %v\n", sitemap.Loc, err)
-			continue
-		}
-		allURLs = append(allURLs, urls...)
+	if p.verbose {
+		fmt.Printf("📥 正在获取: %s\n", sitemapURL)
+	}
 
+	// 打开sitemap内容
+	body, err := p.open(ctx, sitemapURL, local)
+	if err != nil {
+		return fmt.Errorf("获取sitemap失败: %w", err)
+	}
+	defer body.Close()
+
+	count := 0
+	var children []Sitemap
+	kind, err := decode(body,
+		func(u URL) error {
+			count++
+			return emit(p.convertURL(u))
+		},
+		func(sm Sitemap) error {
+			children = append(children, sm)
+			return nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := body.Complete(); err != nil && p.verbose {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	// 子sitemap开始下载前释放当前连接
+	body.Close()
+
+	if kind == kindSitemapIndex {
 		if p.verbose {
-			fmt.Printf("   累计 URL: %d\n", len(allURLs))
+			fmt.Printf("✓ 识别为 Sitemap Index，包含 %d 个子 sitemap\n", len(children))
 		}
+		return p.parseIndex(ctx, path, children, emit)
 	}
 
 	if p.verbose {
-		fmt.Printf("\n✅ 递归解析完成！总计找到 %d 个URL\n\n", len(allURLs))
+		fmt.Printf("✓ 识别为 %s，包含 %d 个URL\n", kindNames[kind], count)
 	}
 
-	return allURLs, nil
+	return nil
 }
 
-// fetch 下载sitemap内容
-func (p *Parser) fetch(url string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+// parseIndex 解析sitemap索引
+// 子sitemap由固定数量的worker并发下载，按索引中的顺序依次输出，已输出的结果随即释放。
+// 第 i 个子sitemap要等到 i-next < workers 才会开始下载，因此即使排在前面的子sitemap很慢，
+// 内存中最多也只保留 workers 个子sitemap的结果
+func (p *Parser) parseIndex(ctx context.Context, path []string, children []Sitemap, emit func(types.SitemapURL) error) error {
+	total := len(children)
+	if total == 0 {
+		return nil
 	}
 
-	req.Header.Set("User-Agent", "Submit-Sitemap-Bot/1.0")
-
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return nil, err
+	if p.verbose {
+		fmt.Printf("\n🔄 开始递归解析 %d 个子 sitemap (并发 %d)...\n", total, p.concurrent)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
+	workers := p.concurrent
+	if workers > total {
+		workers = total
 	}
 
-	var reader io.Reader = resp.Body
+	innerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]types.SitemapURL, total)
+	ready := make([]bool, total)
+	next, done, emitted := 0, 0, 0
+	var emitErr error
+	var mu sync.Mutex
+
+	jobs := make(chan int)
+	// window 限制已分配但尚未输出的子sitemap数量，按顺序输出一个才释放一个名额
+	window := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				loc := strings.TrimSpace(children[i].Loc)
+				var urls []types.SitemapURL
+				err := p.parse(innerCtx, loc, path, false, func(u types.SitemapURL) error {
+					urls = append(urls, u)
+					return nil
+				})
+
+				mu.Lock()
+				if err != nil {
+					if innerCtx.Err() == nil {
+						p.addChildError(loc, err)
+					}
+					urls = nil
+				}
+				results[i] = urls
+				ready[i] = true
+				done++
+				if p.verbose && err == nil {
+					fmt.Printf("   [%d/%d] %s: %d 个URL\n", done, total, loc, len(urls))
+				}
+
+				// 按顺序输出已完成的子sitemap
+				for next < total && ready[next] {
+					for _, u := range results[next] {
+						if emitErr != nil {
+							break
+						}
+						if emitErr = emit(u); emitErr != nil {
+							cancel()
+							break
+						}
+						emitted++
+					}
+					results[next] = nil
+					next++
+					<-window
+				}
+				mu.Unlock()
+			}
+		}()
+	}
 
-	// 如果是gzip压缩，解压
-	if strings.HasSuffix(url, ".gz") || resp.Header.Get("Content-Encoding") == "gzip" {
-		gzReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("解压gzip失败: %w", err)
+feed:
+	for i := range children {
+		select {
+		case window <- struct{}{}:
+		case <-innerCtx.Done():
+			break feed
 		}
-		defer gzReader.Close()
-		reader = gzReader
+		select {
+		case jobs <- i:
+		case <-innerCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if emitErr != nil {
+		return emitErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	return io.ReadAll(reader)
+	if p.verbose {
+		fmt.Printf("\n✅ 递归解析完成！总计找到 %d 个URL\n\n", emitted)
+	}
+
+	return nil
 }
 
-// convertURLs 转换URL格式
-func (p *Parser) convertURLs(urls []URL) []types.SitemapURL {
-	result := make([]types.SitemapURL, len(urls))
-	for i, u := range urls {
-		result[i] = types.SitemapURL{
-			Loc:        u.Loc,
-			LastMod:    u.LastMod,
-			ChangeFreq: u.ChangeFreq,
-			Priority:   u.Priority,
-		}
+// addChildError 记录子sitemap解析失败
+func (p *Parser) addChildError(url string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.childErrors = append(p.childErrors, ChildError{URL: url, Err: err})
+}
+
+// convertURL 转换URL格式
+func (p *Parser) convertURL(u URL) types.SitemapURL {
+	result := types.SitemapURL{
+		Loc:        strings.TrimSpace(u.Loc),
+		LastMod:    strings.TrimSpace(u.LastMod),
+		ChangeFreq: strings.TrimSpace(u.ChangeFreq),
+		Priority:   strings.TrimSpace(u.Priority),
 	}
+	convertExtensions(u, &result)
 	return result
 }
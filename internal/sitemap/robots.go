@@ -0,0 +1,79 @@
+package sitemap
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// RobotsURL 返回域名对应的 robots.txt 地址，域名未带协议时默认使用https
+func RobotsURL(domain string) string {
+	domain = strings.TrimRight(strings.TrimSpace(domain), "/")
+	if !isHTTPURL(domain) {
+		domain = "https://" + domain
+	}
+	return domain + "/robots.txt"
+}
+
+// Discover 读取 robots.txt 中的全部 Sitemap 指令，按出现顺序去重返回
+func (p *Parser) Discover(ctx context.Context, domain string) ([]string, error) {
+	robotsURL := RobotsURL(domain)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Submit-Sitemap-Bot/1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取robots.txt失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取robots.txt失败: HTTP状态码: %d", resp.StatusCode)
+	}
+
+	sitemaps, err := parseRobots(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("解析robots.txt失败: %w", err)
+	}
+
+	if p.verbose {
+		fmt.Printf("✓ %s 中发现 %d 个 sitemap\n", robotsURL, len(sitemaps))
+	}
+
+	return sitemaps, nil
+}
+
+// parseRobots 提取 "Sitemap:" 指令，指令名不区分大小写，且不受 User-agent 分组影响
+func parseRobots(r io.Reader) ([]string, error) {
+	var sitemaps []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "sitemap") {
+			continue
+		}
+
+		loc := strings.TrimSpace(value)
+		if !isHTTPURL(loc) || seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		sitemaps = append(sitemaps, loc)
+	}
+
+	return sitemaps, scanner.Err()
+}
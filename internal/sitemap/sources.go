@@ -0,0 +1,173 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// SiteSources 返回站点的全部URL来源，sitemap_url 作为权重为0的默认来源
+func SiteSources(site types.SiteConfig) []types.SourceConfig {
+	var sources []types.SourceConfig
+	if site.SitemapURL != "" {
+		sources = append(sources, types.SourceConfig{URL: site.SitemapURL})
+	}
+	return append(sources, site.Sources...)
+}
+
+// sourceFilter 来源的 include/exclude 过滤规则
+type sourceFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// newSourceFilter 编译来源的过滤规则
+func newSourceFilter(source types.SourceConfig) (*sourceFilter, error) {
+	filter := &sourceFilter{}
+	for _, pattern := range source.Include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 include 规则 %q: %w", pattern, err)
+		}
+		filter.include = append(filter.include, re)
+	}
+	for _, pattern := range source.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 exclude 规则 %q: %w", pattern, err)
+		}
+		filter.exclude = append(filter.exclude, re)
+	}
+	return filter, nil
+}
+
+// Match 判断URL是否保留：未配置include时默认保留，命中exclude时排除
+func (f *sourceFilter) Match(loc string) bool {
+	if len(f.include) > 0 {
+		matched := false
+		for _, re := range f.include {
+			if re.MatchString(loc) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(loc) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSources 解析站点的全部URL来源，过滤后合并去重
+// 开启 discover_sitemaps 时，robots.txt 中声明的sitemap作为权重为0的来源追加在最后
+// 同一URL出现在多个来源时保留权重最高的一条，结果按权重从高到低排列，
+// 同权重内保持来源顺序和文档顺序。开启 submit_alternates 时，hreflang多语言版本
+// 作为独立URL紧跟在规范URL之后，同样经过来源过滤和去重。单个来源失败记录到 ChildErrors，
+// 只有全部来源都失败时才返回错误
+func (p *Parser) ParseSources(ctx context.Context, site types.SiteConfig) ([]types.SitemapURL, error) {
+	p.reset()
+
+	sources := SiteSources(site)
+	if site.DiscoverSitemaps {
+		discovered, err := p.Discover(ctx, site.Domain)
+		if err != nil {
+			if len(sources) == 0 {
+				return nil, err
+			}
+			p.addChildError(RobotsURL(site.Domain), err)
+		}
+
+		configured := make(map[string]bool, len(sources))
+		for _, source := range sources {
+			configured[source.Location()] = true
+		}
+		for _, loc := range discovered {
+			if !configured[loc] {
+				sources = append(sources, types.SourceConfig{URL: loc})
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("网站 %s 未找到任何URL来源", site.Domain)
+	}
+
+	var merged []types.SitemapURL
+	index := make(map[string]int)
+	alternate := make(map[string]bool) // 条目来自其他页面的hreflang声明
+	failed := 0
+	var firstErr error
+
+	for _, source := range sources {
+		location := source.Location()
+
+		filter, err := newSourceFilter(source)
+		if err == nil {
+			// 每个来源独立判断重复的子sitemap，避免不同过滤规则互相影响
+			p.mu.Lock()
+			p.visited = make(map[string]bool)
+			p.mu.Unlock()
+
+			add := func(u types.SitemapURL, canonical bool) {
+				if !filter.Match(u.Loc) {
+					return
+				}
+				if i, ok := index[u.Loc]; ok {
+					// 同权重时，页面自身的条目优先于其他页面声明的多语言版本
+					if u.Weight > merged[i].Weight || (canonical && alternate[u.Loc] && u.Weight == merged[i].Weight) {
+						merged[i] = u
+						alternate[u.Loc] = !canonical
+					}
+					return
+				}
+				index[u.Loc] = len(merged)
+				merged = append(merged, u)
+				alternate[u.Loc] = !canonical
+			}
+
+			err = p.parse(ctx, location, nil, source.File != "", func(u types.SitemapURL) error {
+				u.Weight = source.Weight
+				add(u, true)
+				if site.SubmitAlternates && filter.Match(u.Loc) {
+					for _, alt := range alternateURLs(u) {
+						add(alt, false)
+					}
+				}
+				return nil
+			})
+		}
+
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			p.addChildError(location, err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if p.verbose {
+			fmt.Printf("✓ 来源 %s (权重 %d) 解析完成\n", location, source.Weight)
+		}
+	}
+
+	if failed == len(sources) {
+		return nil, firstErr
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Weight > merged[j].Weight
+	})
+
+	return merged, nil
+}
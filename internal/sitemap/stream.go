@@ -0,0 +1,305 @@
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// 文档类型，XML文档取根元素名
+const (
+	kindURLSet       = "urlset"
+	kindSitemapIndex = "sitemapindex"
+	kindRSS          = "rss"
+	kindAtom         = "feed"
+	kindText         = "text"
+)
+
+// decode 按内容嗅探文档格式并流式解码
+// 非XML内容按纯文本sitemap处理，XML文档按根元素区分sitemap、RSS和Atom
+func decode(r io.Reader, onURL func(URL) error, onSitemap func(Sitemap) error) (string, error) {
+	buffered := bufio.NewReader(r)
+	if !looksLikeXML(buffered) {
+		return kindText, decodeText(buffered, onURL)
+	}
+	return decodeStream(buffered, onURL, onSitemap)
+}
+
+// decodeStream 使用 xml.Decoder 逐个解码 <url>/<sitemap>/<item>/<entry> 元素
+// 根元素决定文档类型，内存占用与文档大小无关
+func decodeStream(r io.Reader, onURL func(URL) error, onSitemap func(Sitemap) error) (string, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+
+	kind := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return kind, fmt.Errorf("解析sitemap失败: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if kind == "" {
+			switch start.Name.Local {
+			case kindURLSet, kindSitemapIndex, kindRSS, kindAtom:
+				kind = start.Name.Local
+				continue
+			default:
+				return "", fmt.Errorf("解析sitemap失败: 未知的根元素 <%s>", start.Name.Local)
+			}
+		}
+
+		switch {
+		case kind == kindURLSet && start.Name.Local == "url":
+			var u URL
+			if err := decoder.DecodeElement(&u, &start); err != nil {
+				return kind, fmt.Errorf("解析sitemap失败: %w", err)
+			}
+			if err := onURL(u); err != nil {
+				return kind, err
+			}
+		case kind == kindSitemapIndex && start.Name.Local == "sitemap":
+			var sm Sitemap
+			if err := decoder.DecodeElement(&sm, &start); err != nil {
+				return kind, fmt.Errorf("解析sitemap失败: %w", err)
+			}
+			if err := onSitemap(sm); err != nil {
+				return kind, err
+			}
+		case kind == kindRSS && start.Name.Local == "channel":
+			// 进入channel继续查找item
+		case kind == kindRSS && start.Name.Local == "item":
+			var item rssItem
+			if err := decoder.DecodeElement(&item, &start); err != nil {
+				return kind, fmt.Errorf("解析RSS失败: %w", err)
+			}
+			if u, ok := item.toURL(); ok {
+				if err := onURL(u); err != nil {
+					return kind, err
+				}
+			}
+		case kind == kindAtom && start.Name.Local == "entry":
+			var entry atomEntry
+			if err := decoder.DecodeElement(&entry, &start); err != nil {
+				return kind, fmt.Errorf("解析Atom失败: %w", err)
+			}
+			if u, ok := entry.toURL(); ok {
+				if err := onURL(u); err != nil {
+					return kind, err
+				}
+			}
+		default:
+			if err := decoder.Skip(); err != nil {
+				return kind, fmt.Errorf("解析sitemap失败: %w", err)
+			}
+		}
+	}
+
+	if kind == "" {
+		return "", fmt.Errorf("解析sitemap失败: 文档为空")
+	}
+
+	return kind, nil
+}
+
+// sitemapBody 流式读取的sitemap正文（已解压）
+// 从网络读取时同步写入缓存，只有 Complete 后才提交缓存
+type sitemapBody struct {
+	reader  io.Reader
+	closers []func() error
+	cache   *CacheWriter
+	release func()
+}
+
+// Read 实现io.Reader
+func (b *sitemapBody) Read(buf []byte) (int, error) {
+	return b.reader.Read(buf)
+}
+
+// Complete 读完剩余内容并提交缓存
+func (b *sitemapBody) Complete() error {
+	if b.cache == nil {
+		return nil
+	}
+	if _, err := io.Copy(io.Discard, b.reader); err != nil {
+		return err
+	}
+	cache := b.cache
+	b.cache = nil
+	return cache.Commit()
+}
+
+// Close 关闭底层连接，未提交的缓存会被丢弃
+func (b *sitemapBody) Close() error {
+	if b.cache != nil {
+		b.cache.Abort()
+		b.cache = nil
+	}
+
+	var firstErr error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if err := b.closers[i](); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	b.closers = nil
+
+	if b.release != nil {
+		b.release()
+		b.release = nil
+	}
+	return firstErr
+}
+
+// decompress 按gzip魔数判断是否需要解压，不依赖扩展名或 Content-Encoding
+func (b *sitemapBody) decompress(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return buffered, nil
+	}
+
+	gzReader, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("解压gzip失败: %w", err)
+	}
+	b.closers = append(b.closers, gzReader.Close)
+	return gzReader, nil
+}
+
+// openFile 打开本地sitemap文件
+func openFile(path string) (*sitemapBody, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &sitemapBody{closers: []func() error{file.Close}}
+	reader, err := body.decompress(file)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	body.reader = reader
+	return body, nil
+}
+
+// open 打开sitemap正文流
+// local 为 true 时按本地文件读取，否则只接受http(s)地址；启用缓存时，有效期内直接读取缓存，
+// 过期后发送条件请求，304时沿用缓存内容
+func (p *Parser) open(ctx context.Context, url string, local bool) (*sitemapBody, error) {
+	if local {
+		return openFile(strings.TrimPrefix(url, "file://"))
+	}
+	if !isHTTPURL(url) {
+		return nil, fmt.Errorf("不支持的sitemap地址 %q：只接受 http(s) 地址，本地文件需配置为 file 来源", url)
+	}
+
+	var cached *CacheEntry
+	if p.cache != nil && !p.refresh {
+		entry, err := p.cache.Lookup(url)
+		if err != nil && p.verbose {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+		if p.cache.Fresh(entry) {
+			if file, err := p.cache.Open(url); err == nil {
+				if p.verbose {
+					fmt.Printf("✓ 使用缓存: %s\n", url)
+				}
+				return &sitemapBody{reader: file, closers: []func() error{file.Close}}, nil
+			}
+		}
+		cached = entry
+	}
+
+	// 限制同时进行的下载数，直到正文读取完毕才释放
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	body := &sitemapBody{release: func() { <-p.sem }}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", "Submit-Sitemap-Bot/1.0")
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	body.closers = append(body.closers, resp.Body.Close)
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		body.Close()
+		if p.verbose {
+			fmt.Printf("✓ 未修改，使用缓存: %s\n", url)
+		}
+		if err := p.cache.Touch(cached); err != nil && p.verbose {
+			fmt.Printf("⚠️  更新缓存失败: %v\n", err)
+		}
+		file, err := p.cache.Open(url)
+		if err != nil {
+			return nil, err
+		}
+		return &sitemapBody{reader: file, closers: []func() error{file.Close}}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body.Close()
+		return nil, fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
+	}
+
+	reader, err := body.decompress(resp.Body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	if p.cache != nil {
+		writer, err := p.cache.Create(CacheEntry{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+		})
+		if err != nil {
+			if p.verbose {
+				fmt.Printf("⚠️  %v\n", err)
+			}
+		} else {
+			body.cache = writer
+			reader = io.TeeReader(reader, writer)
+		}
+	}
+
+	body.reader = reader
+	return body, nil
+}
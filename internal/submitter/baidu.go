@@ -2,6 +2,7 @@ package submitter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,39 +10,131 @@ import (
 	"strings"
 	"time"
 
+	"github.com/k12/submit-sitemap/internal/httpretry"
 	"github.com/k12/submit-sitemap/pkg/types"
 )
 
+// 百度推送方式
+const (
+	BaiduModeURLs   = "urls"   // 普通收录
+	BaiduModeDaily  = "daily"  // 快速收录，配额单独计算
+	BaiduModeAMP    = "amp"    // AMP页面
+	BaiduModeMIP    = "mip"    // MIP页面
+	BaiduModeUpdate = "update" // 更新已收录的页面
+	BaiduModeDel    = "del"    // 删除已失效的页面
+)
+
+// baiduModeNames 各推送方式的平台名称
+var baiduModeNames = map[string]string{
+	BaiduModeURLs:   "百度",
+	BaiduModeDaily:  "百度快速收录",
+	BaiduModeAMP:    "百度AMP",
+	BaiduModeMIP:    "百度MIP",
+	BaiduModeUpdate: "百度更新",
+	BaiduModeDel:    "百度删除",
+}
+
 // BaiduSubmitter 百度提交器
 type BaiduSubmitter struct {
 	client *http.Client
 	token  string
 	site   string
+	mode   string
 }
 
 // BaiduResponse 百度API响应
+// 快速收录、AMP、MIP 接口的成功数和剩余配额带有 _daily、_amp、_mip 后缀
 type BaiduResponse struct {
-	Success     int      `json:"success"`
-	Remain      int      `json:"remain"`
-	NotSameSite []string `json:"not_same_site"`
-	NotValid    []string `json:"not_valid"`
+	Success      int      `json:"success"`
+	Remain       int      `json:"remain"`
+	SuccessDaily int      `json:"success_daily"`
+	RemainDaily  int      `json:"remain_daily"`
+	SuccessAMP   int      `json:"success_amp"`
+	RemainAMP    int      `json:"remain_amp"`
+	SuccessMIP   int      `json:"success_mip"`
+	RemainMIP    int      `json:"remain_mip"`
+	NotSameSite  []string `json:"not_same_site"`
+	NotValid     []string `json:"not_valid"`
+}
+
+// normalize 将带后缀的字段归一到 Success、Remain
+func (r *BaiduResponse) normalize(mode string) {
+	switch mode {
+	case BaiduModeDaily:
+		r.Success, r.Remain = r.SuccessDaily, r.RemainDaily
+	case BaiduModeAMP:
+		r.Success, r.Remain = r.SuccessAMP, r.RemainAMP
+	case BaiduModeMIP:
+		r.Success, r.Remain = r.SuccessMIP, r.RemainMIP
+	}
+}
+
+// baiduEngineModes 单独注册为引擎的推送方式，拥有独立的配额、历史记录和重试队列
+// 更新和删除推送的是已收录或已失效的页面，不能与普通收录共用URL和历史记录
+var baiduEngineModes = map[string]string{
+	"baidu_daily":  BaiduModeDaily,
+	"baidu_update": BaiduModeUpdate,
+	"baidu_del":    BaiduModeDel,
+}
+
+func init() {
+	RegisterEngine("baidu", func(config types.BaiduConfig, timeout int) (Submitter, error) {
+		if err := validateBaiduConfig(config); err != nil {
+			return nil, fmt.Errorf("baidu: %w", err)
+		}
+		return NewBaiduSubmitter(config, timeout), nil
+	})
+
+	for name, mode := range baiduEngineModes {
+		RegisterEngine(name, func(config types.BaiduConfig, timeout int) (Submitter, error) {
+			config.Mode = ""
+			if err := validateBaiduConfig(config); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			config.Mode = mode
+			return NewBaiduSubmitter(config, timeout), nil
+		})
+	}
+}
+
+// validateBaiduConfig 检查必填字段和推送方式，mode 只能选择普通收录、AMP、MIP
+func validateBaiduConfig(config types.BaiduConfig) error {
+	if config.Token == "" || config.Site == "" {
+		return fmt.Errorf("token 和 site 不能为空")
+	}
+	switch config.Mode {
+	case "", BaiduModeURLs, BaiduModeAMP, BaiduModeMIP:
+		return nil
+	case BaiduModeDaily, BaiduModeUpdate, BaiduModeDel:
+		return fmt.Errorf("推送方式 %q 请改用 baidu_%s 引擎", config.Mode, config.Mode)
+	}
+	return fmt.Errorf("未知的推送方式 %q（可用: urls, amp, mip）", config.Mode)
 }
 
-// NewBaiduSubmitter 创建百度提交器
+// NewBaiduSubmitter 创建百度提交器，未配置推送方式时使用普通收录
 func NewBaiduSubmitter(config types.BaiduConfig, timeout int) *BaiduSubmitter {
+	mode := config.Mode
+	if _, ok := baiduModeNames[mode]; !ok {
+		mode = BaiduModeURLs
+	}
+
 	return &BaiduSubmitter{
-		client: &http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
-		},
-		token: config.Token,
-		site:  config.Site,
+		client: httpretry.NewClient(time.Duration(timeout) * time.Second),
+		token:  config.Token,
+		site:   config.Site,
+		mode:   mode,
 	}
 }
 
 // Submit 提交URL到百度
 func (b *BaiduSubmitter) Submit(urls []string) types.SubmitResult {
-	result := types.SubmitResult{
-		Platform:   "百度",
+	return b.SubmitContext(context.Background(), urls)
+}
+
+// SubmitContext 提交URL到百度，ctx 取消时中止正在进行的请求
+func (b *BaiduSubmitter) SubmitContext(ctx context.Context, urls []string) (result types.SubmitResult) {
+	result = types.SubmitResult{
+		Platform:   b.Name(),
 		TotalCount: len(urls),
 	}
 
@@ -49,7 +142,11 @@ func (b *BaiduSubmitter) Submit(urls []string) types.SubmitResult {
 		return result
 	}
 
-	baiduResp, statusCode, respBody, err := b.submitRaw(urls)
+	// 记录实际请求次数（含重试和逐条提交）
+	ctx, counter := httpretry.WithCounter(ctx)
+	defer func() { result.Attempts = counter.Attempts() }()
+
+	baiduResp, statusCode, respBody, err := b.submitRaw(ctx, urls)
 	if err != nil {
 		result.Error = err
 		result.FailedCount = len(urls)
@@ -59,9 +156,13 @@ func (b *BaiduSubmitter) Submit(urls []string) types.SubmitResult {
 
 	if statusCode != http.StatusOK {
 		if statusCode == http.StatusBadRequest && isOverQuota(respBody) && len(urls) > 1 {
-			return b.submitOneByOne(urls)
+			return b.submitOneByOne(ctx, urls)
 		}
 		result.Error = fmt.Errorf("HTTP错误 - 状态码: %d, 响应: %s", statusCode, string(respBody))
+		if statusCode == http.StatusBadRequest && isOverQuota(respBody) {
+			result.Remain, result.RemainKnown = 0, true
+			result.Error = fmt.Errorf("%w (响应: %s)", types.ErrOverQuota, string(respBody))
+		}
 		result.FailedCount = len(urls)
 		result.FailedURLs = append(result.FailedURLs, urls...)
 		return result
@@ -70,10 +171,12 @@ func (b *BaiduSubmitter) Submit(urls []string) types.SubmitResult {
 	// 设置结果
 	result.SuccessCount = baiduResp.Success
 	result.FailedCount = len(urls) - baiduResp.Success
+	result.Remain, result.RemainKnown = baiduResp.Remain, true
 
 	// 记录失败的URL
 	result.FailedURLs = append(result.FailedURLs, baiduResp.NotSameSite...)
 	result.FailedURLs = append(result.FailedURLs, baiduResp.NotValid...)
+	markPermanent(&result, baiduResp)
 
 	// 如果有失败的URL，添加错误信息
 	if len(result.FailedURLs) > 0 {
@@ -85,13 +188,13 @@ func (b *BaiduSubmitter) Submit(urls []string) types.SubmitResult {
 	return result
 }
 
-func (b *BaiduSubmitter) submitRaw(urls []string) (BaiduResponse, int, []byte, error) {
+func (b *BaiduSubmitter) submitRaw(ctx context.Context, urls []string) (BaiduResponse, int, []byte, error) {
 	var empty BaiduResponse
 
-	apiURL := fmt.Sprintf("http://data.zz.baidu.com/urls?site=%s&token=%s", b.site, b.token)
+	apiURL := b.apiURL()
 	body := strings.Join(urls, "\n")
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBufferString(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBufferString(body))
 	if err != nil {
 		return empty, 0, nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -117,18 +220,26 @@ func (b *BaiduSubmitter) submitRaw(urls []string) (BaiduResponse, int, []byte, e
 	if err := json.Unmarshal(respBody, &baiduResp); err != nil {
 		return empty, resp.StatusCode, respBody, fmt.Errorf("解析响应失败: %w (响应: %s)", err, string(respBody))
 	}
+	baiduResp.normalize(b.mode)
 
 	return baiduResp, resp.StatusCode, respBody, nil
 }
 
-func (b *BaiduSubmitter) submitOneByOne(urls []string) types.SubmitResult {
+func (b *BaiduSubmitter) submitOneByOne(ctx context.Context, urls []string) types.SubmitResult {
 	result := types.SubmitResult{
-		Platform:   "百度",
+		Platform:   b.Name(),
 		TotalCount: len(urls),
 	}
 
 	for i, u := range urls {
-		baiduResp, statusCode, respBody, err := b.submitRaw([]string{u})
+		if err := ctx.Err(); err != nil {
+			result.Error = err
+			result.FailedURLs = append(result.FailedURLs, urls[i:]...)
+			result.FailedCount = len(result.FailedURLs)
+			return result
+		}
+
+		baiduResp, statusCode, respBody, err := b.submitRaw(ctx, []string{u})
 		if err != nil {
 			result.Error = err
 			result.FailedURLs = append(result.FailedURLs, urls[i:]...)
@@ -138,7 +249,8 @@ func (b *BaiduSubmitter) submitOneByOne(urls []string) types.SubmitResult {
 
 		if statusCode != http.StatusOK {
 			if statusCode == http.StatusBadRequest && isOverQuota(respBody) {
-				result.Error = fmt.Errorf("over quota")
+				result.Remain, result.RemainKnown = 0, true
+				result.Error = types.ErrOverQuota
 				result.FailedURLs = append(result.FailedURLs, urls[i:]...)
 				result.FailedCount = len(result.FailedURLs)
 				return result
@@ -149,8 +261,16 @@ func (b *BaiduSubmitter) submitOneByOne(urls []string) types.SubmitResult {
 			continue
 		}
 
+		result.Remain, result.RemainKnown = baiduResp.Remain, true
 		if baiduResp.Success > 0 {
 			result.SuccessCount += baiduResp.Success
+			// 配额已用完，剩余URL不再逐条尝试
+			if baiduResp.Remain <= 0 && i+1 < len(urls) {
+				result.FailedURLs = append(result.FailedURLs, urls[i+1:]...)
+				result.FailedCount = len(result.FailedURLs)
+				result.Error = types.ErrOverQuota
+				return result
+			}
 			continue
 		}
 
@@ -158,6 +278,7 @@ func (b *BaiduSubmitter) submitOneByOne(urls []string) types.SubmitResult {
 			result.FailedURLs = append(result.FailedURLs, baiduResp.NotSameSite...)
 			result.FailedURLs = append(result.FailedURLs, baiduResp.NotValid...)
 			result.FailedCount += len(baiduResp.NotSameSite) + len(baiduResp.NotValid)
+			markPermanent(&result, baiduResp)
 			continue
 		}
 
@@ -172,12 +293,49 @@ func (b *BaiduSubmitter) submitOneByOne(urls []string) types.SubmitResult {
 	return result
 }
 
+// markPermanent 标记不可重试的URL：不属于该站点或不合法的URL重试也不会成功
+func markPermanent(result *types.SubmitResult, baiduResp BaiduResponse) {
+	if len(baiduResp.NotSameSite) == 0 && len(baiduResp.NotValid) == 0 {
+		return
+	}
+	if result.Permanent == nil {
+		result.Permanent = make(map[string]string)
+	}
+	for _, u := range baiduResp.NotSameSite {
+		result.Permanent[u] = "not_same_site"
+	}
+	for _, u := range baiduResp.NotValid {
+		result.Permanent[u] = "not_valid"
+	}
+}
+
+// apiURL 返回当前推送方式的接口地址
+// 更新和删除使用单独的路径，快速收录、AMP、MIP 通过 type 参数区分
+func (b *BaiduSubmitter) apiURL() string {
+	path := "urls"
+	if b.mode == BaiduModeUpdate || b.mode == BaiduModeDel {
+		path = b.mode
+	}
+
+	apiURL := fmt.Sprintf("http://data.zz.baidu.com/%s?site=%s&token=%s", path, b.site, b.token)
+	switch b.mode {
+	case BaiduModeDaily, BaiduModeAMP, BaiduModeMIP:
+		apiURL += "&type=" + b.mode
+	}
+	return apiURL
+}
+
 func isOverQuota(respBody []byte) bool {
 	msg := strings.ToLower(string(respBody))
 	return strings.Contains(msg, "over quota")
 }
 
+// Limits 百度推送接口每次最多推送2000条，请求体为每行一个URL
+func (b *BaiduSubmitter) Limits() BatchLimits {
+	return BatchLimits{MaxURLs: 2000, URLOverhead: 1}
+}
+
 // Name 返回提交器名称
 func (b *BaiduSubmitter) Name() string {
-	return "百度"
+	return baiduModeNames[b.mode]
 }
@@ -1,47 +1,91 @@
 package submitter
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/k12/submit-sitemap/internal/httpretry"
 	"github.com/k12/submit-sitemap/pkg/types"
 )
 
-// GoogleSubmitter Google提交器 (使用IndexNow协议)
-// 注意: Google官方的Indexing API仅支持特定类型内容
-// 这里使用IndexNow协议，虽然Google目前不支持，但保留接口便于未来扩展
+const (
+	defaultGoogleEndpoint = "https://indexing.googleapis.com/batch"
+	googlePublishPath     = "/v3/urlNotifications:publish"
+	googleMaxBatchSize    = 100 // 批量接口单次最多100个请求
+)
+
+// GoogleSubmitter Google提交器 (使用Indexing API)
+// 使用服务账号签发OAuth2令牌，通过批量接口发送 URL_UPDATED 通知
 type GoogleSubmitter struct {
-	client      *http.Client
-	apiKey      string
-	host        string
-	keyLocation string
+	client   *http.Client
+	endpoint string
+	tokens   *googleTokenSource
+}
+
+// googleNotification Indexing API通知请求体
+type googleNotification struct {
+	URL  string `json:"url"`
+	Type string `json:"type"`
+}
+
+// googleErrorResponse Indexing API错误响应
+type googleErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+func init() {
+	RegisterEngine("google", func(config types.GoogleConfig, timeout int) (Submitter, error) {
+		if config.CredentialsFile == "" {
+			return nil, fmt.Errorf("google: credentials_file 不能为空")
+		}
+		return NewGoogleSubmitter(config, timeout), nil
+	})
 }
 
 // NewGoogleSubmitter 创建Google提交器
+// 服务账号密钥在首次提交时加载，加载失败会体现在提交结果中
 func NewGoogleSubmitter(config types.GoogleConfig, timeout int) *GoogleSubmitter {
-	keyLocation := config.KeyLocation
-	// 如果没有配置 keyLocation，使用默认格式
-	if keyLocation == "" {
-		keyLocation = fmt.Sprintf("https://%s/%s.txt", config.Host, config.APIKey)
+	client := httpretry.NewClient(time.Duration(timeout) * time.Second)
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultGoogleEndpoint
 	}
 
 	return &GoogleSubmitter{
-		client: &http.Client{
-			Timeout: time.Duration(timeout) * time.Second,
+		client:   client,
+		endpoint: endpoint,
+		tokens: &googleTokenSource{
+			client:          client,
+			credentialsFile: config.CredentialsFile,
+			tokenURL:        config.TokenURL,
 		},
-		apiKey:      config.APIKey,
-		host:        config.Host,
-		keyLocation: keyLocation,
 	}
 }
 
 // Submit 提交URL到Google
 func (g *GoogleSubmitter) Submit(urls []string) types.SubmitResult {
-	result := types.SubmitResult{
+	return g.SubmitContext(context.Background(), urls)
+}
+
+// SubmitContext 提交URL到Google，ctx 取消时中止正在进行的请求
+func (g *GoogleSubmitter) SubmitContext(ctx context.Context, urls []string) (result types.SubmitResult) {
+	result = types.SubmitResult{
 		Platform:   "Google",
 		TotalCount: len(urls),
 	}
@@ -50,59 +94,223 @@ func (g *GoogleSubmitter) Submit(urls []string) types.SubmitResult {
 		return result
 	}
 
-	// 使用IndexNow协议提交
-	// 注意：Google目前不支持IndexNow，但保留此实现以便未来使用
-	apiURL := "https://api.indexnow.org/indexnow"
-
-	// 准备请求体
-	reqBody := IndexNowRequest{
-		Host:        g.host,
-		Key:         g.apiKey,
-		KeyLocation: g.keyLocation,
-		URLList:     urls,
-	}
+	// 记录实际请求次数（含令牌请求和重试）
+	ctx, counter := httpretry.WithCounter(ctx)
+	defer func() { result.Attempts = counter.Attempts() }()
 
-	jsonData, err := json.Marshal(reqBody)
+	token, err := g.tokens.Token(ctx)
 	if err != nil {
-		result.Error = fmt.Errorf("序列化请求失败: %w", err)
+		result.Error = err
 		result.FailedCount = len(urls)
+		result.FailedURLs = append(result.FailedURLs, urls...)
 		return result
 	}
 
-	// 创建请求
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	var failedInfo []string
+	overQuota := false
+	for i := 0; i < len(urls); i += googleMaxBatchSize {
+		end := i + googleMaxBatchSize
+		if end > len(urls) {
+			end = len(urls)
+		}
+		batch := urls[i:end]
+
+		// 配额用完后剩余的批次不再发送
+		if overQuota {
+			result.FailedURLs = append(result.FailedURLs, batch...)
+			continue
+		}
+
+		items, err := g.submitBatch(ctx, token, batch)
+		if err != nil {
+			result.Error = err
+			result.FailedURLs = append(result.FailedURLs, urls[i:]...)
+			result.FailedCount = len(result.FailedURLs)
+			return result
+		}
+
+		for j, item := range items {
+			u := batch[j]
+			switch {
+			case item.StatusCode == http.StatusOK:
+				result.SuccessCount++
+				continue
+			case item.overQuota():
+				overQuota = true
+			case item.permanent():
+				if result.Permanent == nil {
+					result.Permanent = make(map[string]string)
+				}
+				result.Permanent[u] = item.String()
+			}
+			result.FailedURLs = append(result.FailedURLs, u)
+			failedInfo = append(failedInfo, fmt.Sprintf("%s (%s)", u, item))
+		}
+	}
+
+	result.FailedCount = len(result.FailedURLs)
+	switch {
+	case overQuota:
+		// Indexing API 不返回剩余配额，收到429说明当天配额已用完
+		result.Remain, result.RemainKnown = 0, true
+		result.Error = fmt.Errorf("%w: %s", types.ErrOverQuota, strings.Join(failedInfo, "; "))
+	case len(failedInfo) > 0:
+		result.Error = fmt.Errorf("部分URL提交失败: %s", strings.Join(failedInfo, "; "))
+	}
+
+	return result
+}
+
+// googleItemResult 批量响应中单个URL的结果
+type googleItemResult struct {
+	StatusCode int    // 子响应状态码，0 表示没有拿到有效的子响应
+	Status     string // 错误状态，如 RESOURCE_EXHAUSTED
+	Message    string // 错误信息
+}
+
+// overQuota 当天配额已用完
+func (r googleItemResult) overQuota() bool {
+	return r.StatusCode == http.StatusTooManyRequests || r.Status == "RESOURCE_EXHAUSTED"
+}
+
+// permanent 没有权限或URL不存在，修改配置前重试也不会成功
+func (r googleItemResult) permanent() bool {
+	return r.StatusCode == http.StatusForbidden || r.StatusCode == http.StatusNotFound
+}
+
+func (r googleItemResult) String() string {
+	switch {
+	case r.StatusCode == 0:
+		return r.Message
+	case r.Message != "":
+		return fmt.Sprintf("%d %s: %s", r.StatusCode, r.Status, r.Message)
+	default:
+		return fmt.Sprintf("状态码: %d", r.StatusCode)
+	}
+}
+
+// submitBatch 通过批量接口发送一组通知，按请求顺序返回每个URL的结果
+func (g *GoogleSubmitter) submitBatch(ctx context.Context, token string, urls []string) ([]googleItemResult, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for i, u := range urls {
+		payload, err := json.Marshal(googleNotification{URL: u, Type: "URL_UPDATED"})
+		if err != nil {
+			return nil, fmt.Errorf("序列化请求失败: %w", err)
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "application/http")
+		header.Set("Content-ID", fmt.Sprintf("<item%d>", i))
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, fmt.Errorf("构建批量请求失败: %w", err)
+		}
+
+		fmt.Fprintf(part, "POST %s\r\n", googlePublishPath)
+		fmt.Fprintf(part, "Content-Type: application/json\r\n\r\n")
+		part.Write(payload)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("构建批量请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", g.endpoint, &body)
 	if err != nil {
-		result.Error = fmt.Errorf("创建请求失败: %w", err)
-		result.FailedCount = len(urls)
-		return result
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("User-Agent", "Submit-Sitemap-Bot/1.0")
 
-	// 发送请求
 	resp, err := g.client.Do(req)
 	if err != nil {
-		result.Error = fmt.Errorf("发送请求失败: %w", err)
-		result.FailedCount = len(urls)
-		return result
+		return nil, fmt.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 读取响应
-	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("HTTP错误 - 状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
+	}
+
+	return parseGoogleBatchResponse(resp, urls)
+}
 
-	// IndexNow返回200或202表示成功
-	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted {
-		result.SuccessCount = len(urls)
-		result.FailedCount = 0
-	} else {
-		result.Error = fmt.Errorf("提交失败，状态码: %d, 响应: %s", resp.StatusCode, string(respBody))
-		result.FailedCount = len(urls)
-		result.FailedURLs = urls
+// parseGoogleBatchResponse 解析multipart/mixed批量响应
+// 通过 Content-ID (response-itemN) 将每个子响应对应回请求的URL，返回结果与 urls 一一对应
+func parseGoogleBatchResponse(resp *http.Response, urls []string) ([]googleItemResult, error) {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("批量响应格式错误: %s", resp.Header.Get("Content-Type"))
 	}
 
-	return result
+	items := make([]googleItemResult, len(urls))
+	answered := make(map[int]bool)
+
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取批量响应失败: %w", err)
+		}
+
+		index := googleResponseIndex(part.Header.Get("Content-ID"))
+		if index < 0 || index >= len(urls) {
+			continue
+		}
+		answered[index] = true
+
+		itemResp, err := http.ReadResponse(bufio.NewReader(part), nil)
+		if err != nil {
+			items[index].Message = fmt.Sprintf("解析子响应失败: %v", err)
+			continue
+		}
+		itemBody, _ := io.ReadAll(itemResp.Body)
+		itemResp.Body.Close()
+
+		items[index].StatusCode = itemResp.StatusCode
+		if itemResp.StatusCode == http.StatusOK {
+			continue
+		}
+
+		var errResp googleErrorResponse
+		if err := json.Unmarshal(itemBody, &errResp); err == nil {
+			items[index].Status = errResp.Error.Status
+			items[index].Message = errResp.Error.Message
+		}
+	}
+
+	// 批量响应中缺失的条目视为失败
+	for i := range urls {
+		if !answered[i] {
+			items[i].Message = "批量响应中缺少该URL的结果"
+		}
+	}
+
+	return items, nil
+}
+
+// googleResponseIndex 从 "<response-item3>" 形式的Content-ID中提取序号
+func googleResponseIndex(contentID string) int {
+	id := strings.Trim(contentID, "<>")
+	id = strings.TrimPrefix(id, "response-")
+	id = strings.TrimPrefix(id, "item")
+	index, err := strconv.Atoi(id)
+	if err != nil {
+		return -1
+	}
+	return index
+}
+
+// Limits 批量接口单次最多包含100个请求，每个URL包装为一个带请求头的multipart分段
+func (g *GoogleSubmitter) Limits() BatchLimits {
+	return BatchLimits{MaxURLs: googleMaxBatchSize, URLOverhead: 256}
 }
 
 // Name 返回提交器名称
@@ -0,0 +1,207 @@
+package submitter
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	googleIndexingScope   = "https://www.googleapis.com/auth/indexing"
+	defaultGoogleTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+// serviceAccountKey Google服务账号JSON密钥
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// googleTokenResponse OAuth2令牌响应
+type googleTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// googleTokenSource 使用服务账号签发JWT换取访问令牌，并缓存到过期前
+type googleTokenSource struct {
+	client          *http.Client
+	credentialsFile string
+	tokenURL        string
+
+	mu      sync.Mutex
+	key     *serviceAccountKey
+	signer  *rsa.PrivateKey
+	token   string
+	expires time.Time
+}
+
+// Token 返回有效的访问令牌，过期前60秒自动刷新
+func (s *googleTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Add(60*time.Second).Before(s.expires) {
+		return s.token, nil
+	}
+
+	if s.key == nil {
+		if err := s.loadKey(); err != nil {
+			return "", err
+		}
+	}
+
+	assertion, err := s.signJWT(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	tokenURL := s.tokenURL
+	if tokenURL == "" {
+		tokenURL = s.key.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = defaultGoogleTokenURL
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("创建令牌请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("获取访问令牌失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取令牌响应失败: %w", err)
+	}
+
+	var tokenResp googleTokenResponse
+	if err := json.Unmarshal(respBody, &tokenResp); err != nil {
+		return "", fmt.Errorf("解析令牌响应失败: %w (响应: %s)", err, string(respBody))
+	}
+
+	if resp.StatusCode != http.StatusOK || tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("获取访问令牌失败 - 状态码: %d, 错误: %s %s",
+			resp.StatusCode, tokenResp.Error, tokenResp.Description)
+	}
+
+	s.token = tokenResp.AccessToken
+	s.expires = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+
+	return s.token, nil
+}
+
+// loadKey 读取并解析服务账号密钥文件
+func (s *googleTokenSource) loadKey() error {
+	if s.credentialsFile == "" {
+		return fmt.Errorf("未配置 credentials_file")
+	}
+
+	data, err := os.ReadFile(s.credentialsFile)
+	if err != nil {
+		return fmt.Errorf("读取服务账号密钥失败: %w", err)
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return fmt.Errorf("解析服务账号密钥失败: %w", err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return fmt.Errorf("服务账号密钥缺少 client_email 或 private_key")
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return fmt.Errorf("服务账号私钥不是有效的PEM格式")
+	}
+
+	var signer *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return fmt.Errorf("服务账号私钥不是RSA密钥")
+		}
+		signer = rsaKey
+	} else if rsaKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		signer = rsaKey
+	} else {
+		return fmt.Errorf("解析服务账号私钥失败: %w", err)
+	}
+
+	s.key = &key
+	s.signer = signer
+	return nil
+}
+
+// signJWT 生成RS256签名的JWT断言
+func (s *googleTokenSource) signJWT(now time.Time) (string, error) {
+	aud := s.tokenURL
+	if aud == "" {
+		aud = s.key.TokenURI
+	}
+	if aud == "" {
+		aud = defaultGoogleTokenURL
+	}
+
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if s.key.PrivateKeyID != "" {
+		header["kid"] = s.key.PrivateKeyID
+	}
+	claims := map[string]interface{}{
+		"iss":   s.key.ClientEmail,
+		"scope": googleIndexingScope,
+		"aud":   aud,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(headerJSON) + "." + enc.EncodeToString(claimsJSON)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("签名JWT失败: %w", err)
+	}
+
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,441 @@
+package submitter
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// writeServiceAccount 生成测试用服务账号密钥文件
+func writeServiceAccount(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(serviceAccountKey{
+		Type:         "service_account",
+		ClientEmail:  "bot@example.iam.gserviceaccount.com",
+		PrivateKeyID: "kid-1",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "service-account.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, key
+}
+
+// verifyJWT 校验RS256签名并返回claims，在 handler 中调用，失败时只记录错误
+func verifyJWT(t *testing.T, assertion string, key *rsa.PublicKey) map[string]any {
+	t.Helper()
+
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		t.Errorf("JWT 应有3段，实际 %d 段", len(parts))
+		return nil
+	}
+
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("JWT 签名无效: %v", err)
+	}
+
+	var header map[string]string
+	headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		t.Error(err)
+	}
+	if header["alg"] != "RS256" || header["kid"] != "kid-1" {
+		t.Errorf("JWT header = %v", header)
+	}
+
+	var claims map[string]any
+	claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Error(err)
+	}
+	return claims
+}
+
+// writeBatchPart 写入一个批量响应分段
+func writeBatchPart(t *testing.T, writer *multipart.Writer, index, status int, body string) {
+	t.Helper()
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", "application/http")
+	header.Set("Content-ID", fmt.Sprintf("<response-item%d>", index))
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	fmt.Fprintf(part, "HTTP/1.1 %d %s\r\nContent-Type: application/json\r\n\r\n%s", status, http.StatusText(status), body)
+}
+
+func TestGoogleTokenCachedAcrossSubmits(t *testing.T) {
+	credentials, key := writeServiceAccount(t)
+
+	var tokenRequests, batchRequests atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests.Add(1)
+			if err := r.ParseForm(); err != nil {
+				t.Error(err)
+			}
+			if got := r.PostForm.Get("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+				t.Errorf("grant_type = %q", got)
+			}
+			claims := verifyJWT(t, r.PostForm.Get("assertion"), &key.PublicKey)
+			if claims["iss"] != "bot@example.iam.gserviceaccount.com" {
+				t.Errorf("iss = %v", claims["iss"])
+			}
+			if claims["aud"] != server.URL+"/token" {
+				t.Errorf("aud = %v, 应为配置的 token_url", claims["aud"])
+			}
+			if claims["scope"] != googleIndexingScope {
+				t.Errorf("scope = %v", claims["scope"])
+			}
+			fmt.Fprint(w, `{"access_token":"token-1","token_type":"Bearer","expires_in":3600}`)
+
+		case "/batch":
+			batchRequests.Add(1)
+			if got := r.Header.Get("Authorization"); got != "Bearer token-1" {
+				t.Errorf("Authorization = %q", got)
+			}
+			var body bytes.Buffer
+			writer := multipart.NewWriter(&body)
+			writeBatchPart(t, writer, 0, http.StatusOK, `{}`)
+			writer.Close()
+			w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+			w.Write(body.Bytes())
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	g := NewGoogleSubmitter(types.GoogleConfig{
+		CredentialsFile: credentials,
+		Endpoint:        server.URL + "/batch",
+		TokenURL:        server.URL + "/token",
+	}, 5)
+
+	for i := 0; i < 3; i++ {
+		result := g.Submit([]string{"https://example.com/a"})
+		if result.Error != nil || result.SuccessCount != 1 {
+			t.Fatalf("第 %d 次提交: %+v", i+1, result)
+		}
+	}
+
+	if n := tokenRequests.Load(); n != 1 {
+		t.Errorf("令牌请求 %d 次，应缓存为1次", n)
+	}
+	if n := batchRequests.Load(); n != 3 {
+		t.Errorf("批量请求 %d 次，应为3次", n)
+	}
+}
+
+func TestGoogleTokenRefreshedBeforeExpiry(t *testing.T) {
+	credentials, _ := writeServiceAccount(t)
+
+	var tokenRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := tokenRequests.Add(1)
+		// 60秒内过期的令牌不会被复用
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":30}`, n)
+	}))
+	defer server.Close()
+
+	source := &googleTokenSource{
+		client:          server.Client(),
+		credentialsFile: credentials,
+		tokenURL:        server.URL,
+	}
+
+	first, err := source.Token(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := source.Token(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("即将过期的令牌应重新获取，两次都得到 %q", first)
+	}
+}
+
+func TestGoogleBatchResponseMappedByContentID(t *testing.T) {
+	urls := []string{
+		"https://example.com/0",
+		"https://example.com/1",
+		"https://example.com/2",
+		"https://example.com/3",
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	// 子响应乱序返回，item3 缺失
+	writeBatchPart(t, writer, 2, http.StatusOK, `{}`)
+	writeBatchPart(t, writer, 1, http.StatusForbidden,
+		`{"error":{"code":403,"message":"Permission denied","status":"PERMISSION_DENIED"}}`)
+	writeBatchPart(t, writer, 0, http.StatusOK, `{}`)
+	writer.Close()
+
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": {"multipart/mixed; boundary=" + writer.Boundary()}},
+		Body:   io.NopCloser(&body),
+	}
+
+	items, err := parseGoogleBatchResponse(resp, urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != len(urls) {
+		t.Fatalf("items = %v, 应有 %d 条", items, len(urls))
+	}
+	if item := items[1]; item.StatusCode != http.StatusForbidden || item.Status != "PERMISSION_DENIED" || item.Message != "Permission denied" {
+		t.Errorf("item1 = %+v", item)
+	}
+	if item := items[3]; item.StatusCode != 0 || item.Message == "" {
+		t.Errorf("缺失的 item3 应视为失败: %+v", item)
+	}
+	for _, i := range []int{0, 2} {
+		if items[i].StatusCode != http.StatusOK {
+			t.Errorf("item%d 不应失败: %+v", i, items[i])
+		}
+	}
+}
+
+func TestGoogleSubmitSendsOneNotificationPerURL(t *testing.T) {
+	credentials, _ := writeServiceAccount(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"t","expires_in":3600}`)
+			return
+		}
+
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for i := 0; ; i++ {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := part.Header.Get("Content-ID"); got != fmt.Sprintf("<item%d>", i) {
+				t.Errorf("Content-ID = %q", got)
+			}
+			data, _ := io.ReadAll(part)
+			if !bytes.Contains(data, []byte("POST "+googlePublishPath)) || !bytes.Contains(data, []byte(`"type":"URL_UPDATED"`)) {
+				t.Errorf("分段内容错误: %s", data)
+			}
+
+			// 奇数序号的URL返回临时错误
+			if i%2 == 1 {
+				writeBatchPart(t, writer, i, http.StatusInternalServerError,
+					`{"error":{"code":500,"message":"Internal error","status":"INTERNAL"}}`)
+			} else {
+				writeBatchPart(t, writer, i, http.StatusOK, `{}`)
+			}
+		}
+		writer.Close()
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+		w.Write(body.Bytes())
+	}))
+	defer server.Close()
+
+	g := NewGoogleSubmitter(types.GoogleConfig{
+		CredentialsFile: credentials,
+		Endpoint:        server.URL + "/batch",
+		TokenURL:        server.URL + "/token",
+	}, 5)
+
+	urls := []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}
+	result := g.Submit(urls)
+
+	if result.SuccessCount != 2 || result.FailedCount != 1 {
+		t.Fatalf("结果 = %+v", result)
+	}
+	if len(result.FailedURLs) != 1 || result.FailedURLs[0] != urls[1] {
+		t.Errorf("FailedURLs = %v, 应为 [%s]", result.FailedURLs, urls[1])
+	}
+
+	if len(result.Permanent) != 0 {
+		t.Errorf("临时错误不应标记为永久失败: %v", result.Permanent)
+	}
+}
+
+// newGoogleBatchServer 启动批量接口测试服务，status 决定第 n 个批量请求中每个分段的返回
+func newGoogleBatchServer(t *testing.T, status func(batch, item int) (int, string)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var batches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"t","expires_in":3600}`)
+			return
+		}
+
+		n := int(batches.Add(1)) - 1
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for i := 0; ; i++ {
+			if _, err := reader.NextPart(); err != nil {
+				break
+			}
+			code, resp := status(n, i)
+			writeBatchPart(t, writer, i, code, resp)
+		}
+		writer.Close()
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+		w.Write(body.Bytes())
+	}))
+	t.Cleanup(server.Close)
+	return server, &batches
+}
+
+// googleTestURLs 生成 n 个测试URL
+func googleTestURLs(n int) []string {
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("https://example.com/%d", i)
+	}
+	return urls
+}
+
+func TestGoogleOverQuotaStopsRemainingBatches(t *testing.T) {
+	credentials, _ := writeServiceAccount(t)
+
+	// 第一批的第3个URL起配额用完
+	server, batches := newGoogleBatchServer(t, func(batch, item int) (int, string) {
+		if batch == 0 && item < 3 {
+			return http.StatusOK, `{}`
+		}
+		return http.StatusTooManyRequests, `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`
+	})
+
+	g := NewGoogleSubmitter(types.GoogleConfig{
+		CredentialsFile: credentials,
+		Endpoint:        server.URL + "/batch",
+		TokenURL:        server.URL + "/token",
+	}, 5)
+
+	urls := googleTestURLs(googleMaxBatchSize + 10)
+	result := g.Submit(urls)
+
+	if !errors.Is(result.Error, types.ErrOverQuota) {
+		t.Errorf("Error = %v, 应为 ErrOverQuota", result.Error)
+	}
+	if n := batches.Load(); n != 1 {
+		t.Errorf("批量请求 %d 次，配额用完后不应发送后续批次", n)
+	}
+	if result.SuccessCount != 3 || result.FailedCount != len(urls)-3 || len(result.FailedURLs) != len(urls)-3 {
+		t.Errorf("结果: 成功 %d, 失败 %d (%d 个URL)", result.SuccessCount, result.FailedCount, len(result.FailedURLs))
+	}
+	if !result.RemainKnown || result.Remain != 0 {
+		t.Errorf("Remain = %d (known %v), 应为已知的0", result.Remain, result.RemainKnown)
+	}
+	if len(result.Permanent) != 0 {
+		t.Errorf("配额错误不应标记为永久失败: %v", result.Permanent)
+	}
+}
+
+func TestGooglePermanentErrors(t *testing.T) {
+	credentials, _ := writeServiceAccount(t)
+
+	server, _ := newGoogleBatchServer(t, func(_, item int) (int, string) {
+		switch item {
+		case 1:
+			return http.StatusForbidden, `{"error":{"code":403,"message":"Permission denied","status":"PERMISSION_DENIED"}}`
+		case 2:
+			return http.StatusNotFound, `{"error":{"code":404,"message":"Not found","status":"NOT_FOUND"}}`
+		case 3:
+			return http.StatusServiceUnavailable, `{"error":{"code":503,"message":"Unavailable","status":"UNAVAILABLE"}}`
+		}
+		return http.StatusOK, `{}`
+	})
+
+	g := NewGoogleSubmitter(types.GoogleConfig{
+		CredentialsFile: credentials,
+		Endpoint:        server.URL + "/batch",
+		TokenURL:        server.URL + "/token",
+	}, 5)
+
+	urls := googleTestURLs(4)
+	result := g.Submit(urls)
+
+	if result.SuccessCount != 1 || result.FailedCount != 3 {
+		t.Fatalf("结果 = %+v", result)
+	}
+	if errors.Is(result.Error, types.ErrOverQuota) || result.RemainKnown {
+		t.Errorf("不应视为配额用完: %v", result.Error)
+	}
+	if len(result.Permanent) != 2 {
+		t.Fatalf("Permanent = %v, 应有2条", result.Permanent)
+	}
+	if reason := result.Permanent[urls[1]]; !strings.Contains(reason, "403") {
+		t.Errorf("%s 原因 = %q", urls[1], reason)
+	}
+	if reason := result.Permanent[urls[2]]; !strings.Contains(reason, "404") {
+		t.Errorf("%s 原因 = %q", urls[2], reason)
+	}
+	if _, ok := result.Permanent[urls[3]]; ok {
+		t.Errorf("503 是临时错误，不应标记为永久失败")
+	}
+}
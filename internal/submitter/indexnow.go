@@ -0,0 +1,352 @@
+package submitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/k12/submit-sitemap/internal/httpretry"
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// DefaultIndexNowUserAgent 未配置 user_agent 时使用的 User-Agent
+const DefaultIndexNowUserAgent = "Submit-Sitemap-Bot/1.0"
+
+// IndexNowEndpoints 已知的IndexNow接口地址，配置中可以用名称代替完整地址
+// 任一接口收到的URL都会共享给所有参与IndexNow的搜索引擎
+var IndexNowEndpoints = map[string]string{
+	"indexnow": "https://api.indexnow.org/indexnow",
+	"bing":     "https://www.bing.com/indexnow",
+	"yandex":   "https://yandex.com/indexnow",
+	"seznam":   "https://search.seznam.cz/indexnow",
+	"naver":    "https://searchadvisor.naver.com/indexnow",
+}
+
+// defaultIndexNowEndpoint 未配置 endpoints 时使用的接口
+const defaultIndexNowEndpoint = "https://api.indexnow.org/indexnow"
+
+// resolveIndexNowEndpoints 将接口名称解析为地址，完整的 http(s) 地址原样使用，去除重复
+func resolveIndexNowEndpoints(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return []string{defaultIndexNowEndpoint}, nil
+	}
+
+	var endpoints []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		endpoint := strings.TrimSpace(name)
+		if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+			known, ok := IndexNowEndpoints[strings.ToLower(endpoint)]
+			if !ok {
+				return nil, fmt.Errorf("未知的IndexNow接口 %q（可用: %s，或填写完整地址）", name, knownIndexNowEndpoints())
+			}
+			endpoint = known
+		}
+		if !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+// knownIndexNowEndpoints 已知接口名称列表
+func knownIndexNowEndpoints() string {
+	names := make([]string, 0, len(IndexNowEndpoints))
+	for name := range IndexNowEndpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// IndexNow 响应码对应的错误，可以用 errors.Is 判断
+var (
+	ErrIndexNowBadRequest      = errors.New("IndexNow 请求格式无效 (400)")
+	ErrIndexNowForbidden       = errors.New("IndexNow key 无效 (403)")
+	ErrIndexNowUnprocessable   = errors.New("IndexNow URL不属于该host (422)")
+	ErrIndexNowTooManyRequests = errors.New("IndexNow 请求过于频繁 (429)")
+)
+
+// IndexNowError IndexNow接口返回的错误
+type IndexNowError struct {
+	Endpoint   string
+	StatusCode int
+	Body       string
+	Hint       string // 处理建议
+	kind       error
+}
+
+// Error 实现error接口
+func (e *IndexNowError) Error() string {
+	msg := fmt.Sprintf("%s 提交失败，状态码: %d", e.Endpoint, e.StatusCode)
+	if e.kind != nil {
+		msg = fmt.Sprintf("%s: %v", e.Endpoint, e.kind)
+	}
+	if e.Body != "" {
+		msg += ", 响应: " + e.Body
+	}
+	if e.Hint != "" {
+		msg += "（" + e.Hint + "）"
+	}
+	return msg
+}
+
+// Unwrap 返回响应码对应的错误
+func (e *IndexNowError) Unwrap() error {
+	return e.kind
+}
+
+// Permanent URL不属于该host，修改配置前重试也不会成功
+// 400/403 多为站点配置问题，修正配置后可以重新提交，不视为永久失败
+func (e *IndexNowError) Permanent() bool {
+	return e.kind == ErrIndexNowUnprocessable
+}
+
+// newIndexNowError 根据响应码构造错误
+func newIndexNowError(endpoint string, statusCode int, body []byte) *IndexNowError {
+	e := &IndexNowError{
+		Endpoint:   endpoint,
+		StatusCode: statusCode,
+		Body:       strings.TrimSpace(string(body)),
+	}
+
+	switch statusCode {
+	case http.StatusBadRequest:
+		e.kind = ErrIndexNowBadRequest
+		e.Hint = "检查 host、key 是否填写，URL是否为完整的 http(s) 地址"
+	case http.StatusForbidden:
+		e.kind = ErrIndexNowForbidden
+		e.Hint = "确认 key_location 可以公开访问，且文件内容与 api_key 完全一致"
+	case http.StatusUnprocessableEntity:
+		e.kind = ErrIndexNowUnprocessable
+		e.Hint = "提交的URL必须属于配置的 host，key_location 也必须位于该 host 下"
+	case http.StatusTooManyRequests:
+		e.kind = ErrIndexNowTooManyRequests
+		e.Hint = "降低 rate_limits 或减少每日配额，稍后再试"
+	}
+	return e
+}
+
+// IndexNowRequest IndexNow API请求结构
+type IndexNowRequest struct {
+	Host        string   `json:"host"`
+	Key         string   `json:"key"`
+	KeyLocation string   `json:"keyLocation"`
+	URLList     []string `json:"urlList"`
+}
+
+// IndexNowClient 单个IndexNow接口的客户端
+type IndexNowClient struct {
+	client      *http.Client
+	endpoint    string
+	userAgent   string
+	host        string
+	key         string
+	keyLocation string
+}
+
+// NewIndexNowClient 创建IndexNow客户端，client 为 nil 时使用带重试的默认客户端
+func NewIndexNowClient(client *http.Client, endpoint string, config types.IndexNowConfig, timeout int) *IndexNowClient {
+	if client == nil {
+		client = httpretry.NewClient(time.Duration(timeout) * time.Second)
+	}
+
+	userAgent := config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultIndexNowUserAgent
+	}
+
+	return &IndexNowClient{
+		client:      client,
+		endpoint:    endpoint,
+		userAgent:   userAgent,
+		host:        config.Host,
+		key:         config.APIKey,
+		keyLocation: IndexNowKeyLocation(config),
+	}
+}
+
+// IndexNowKeyLocation 返回key文件地址，未配置时使用 https://<host>/<api_key>.txt
+func IndexNowKeyLocation(config types.IndexNowConfig) string {
+	if config.KeyLocation != "" {
+		return config.KeyLocation
+	}
+	return fmt.Sprintf("https://%s/%s.txt", config.Host, config.APIKey)
+}
+
+// Endpoint 返回接口地址
+func (c *IndexNowClient) Endpoint() string {
+	return c.endpoint
+}
+
+// Submit 将URL提交到该接口，非200/202响应返回 *IndexNowError
+func (c *IndexNowClient) Submit(ctx context.Context, urls []string) types.EndpointResult {
+	result := types.EndpointResult{Endpoint: c.endpoint}
+
+	jsonData, err := json.Marshal(IndexNowRequest{
+		Host:        c.host,
+		Key:         c.key,
+		KeyLocation: c.keyLocation,
+		URLList:     urls,
+	})
+	if err != nil {
+		result.Error = fmt.Errorf("序列化请求失败: %w", err)
+		return result
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		result.Error = fmt.Errorf("创建请求失败: %w", err)
+		return result
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	// 发送请求
+	resp, err := c.client.Do(req)
+	if err != nil {
+		result.Error = fmt.Errorf("%s 发送请求失败: %w", c.endpoint, err)
+		return result
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	respBody, _ := io.ReadAll(resp.Body)
+	result.StatusCode = resp.StatusCode
+
+	// IndexNow返回200或202表示成功
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		result.Error = newIndexNowError(c.endpoint, resp.StatusCode, respBody)
+	}
+	return result
+}
+
+// IndexNowSubmitter 通过IndexNow协议提交的提交器
+// 配置了多个接口时，同一批URL会依次提交到每个接口
+type IndexNowSubmitter struct {
+	name    string
+	clients []*IndexNowClient
+}
+
+func init() {
+	RegisterEngine("indexnow", func(config types.IndexNowConfig, timeout int) (Submitter, error) {
+		if err := validateIndexNowConfig(config); err != nil {
+			return nil, fmt.Errorf("indexnow: %w", err)
+		}
+		s, err := NewIndexNowSubmitter("IndexNow", config, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("indexnow: %w", err)
+		}
+		return s, nil
+	})
+}
+
+// validateIndexNowConfig 检查必填字段，接口列表由 NewIndexNowSubmitter 检查
+func validateIndexNowConfig(config types.IndexNowConfig) error {
+	if config.APIKey == "" || config.Host == "" {
+		return fmt.Errorf("api_key 和 host 不能为空")
+	}
+	return nil
+}
+
+// NewIndexNowSubmitter 创建IndexNow提交器，name 为平台名称，未配置 endpoints 时使用默认接口
+// 接口名称未知时返回错误，不会悄悄改用默认接口
+func NewIndexNowSubmitter(name string, config types.IndexNowConfig, timeout int) (*IndexNowSubmitter, error) {
+	endpoints, err := resolveIndexNowEndpoints(config.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	// 所有接口共用一个HTTP客户端
+	client := httpretry.NewClient(time.Duration(timeout) * time.Second)
+	clients := make([]*IndexNowClient, len(endpoints))
+	for i, endpoint := range endpoints {
+		clients[i] = NewIndexNowClient(client, endpoint, config, timeout)
+	}
+
+	return &IndexNowSubmitter{name: name, clients: clients}, nil
+}
+
+// Submit 提交URL到所有接口
+func (s *IndexNowSubmitter) Submit(urls []string) types.SubmitResult {
+	return s.SubmitContext(context.Background(), urls)
+}
+
+// SubmitContext 提交URL到所有接口，ctx 取消时中止正在进行的请求
+// 任一接口接受即视为提交成功，各接口的结果记录在 result.Endpoints 中；
+// 提交成功时其他接口的失败不会进入 result.Error，因此逐个输出警告
+func (s *IndexNowSubmitter) SubmitContext(ctx context.Context, urls []string) (result types.SubmitResult) {
+	result = types.SubmitResult{
+		Platform:   s.name,
+		TotalCount: len(urls),
+	}
+
+	if len(urls) == 0 {
+		return result
+	}
+
+	// 记录实际请求次数（含重试）
+	ctx, counter := httpretry.WithCounter(ctx)
+	defer func() { result.Attempts = counter.Attempts() }()
+
+	var errs []error
+	accepted, permanent := false, true
+	for _, client := range s.clients {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			permanent = false
+			break
+		}
+
+		r := client.Submit(ctx, urls)
+		result.Endpoints = append(result.Endpoints, r)
+		if r.Error == nil {
+			accepted = true
+			continue
+		}
+
+		errs = append(errs, r.Error)
+		var indexNowErr *IndexNowError
+		if !errors.As(r.Error, &indexNowErr) || !indexNowErr.Permanent() {
+			permanent = false
+		}
+	}
+
+	if accepted {
+		// 其他接口的错误保留在 result.Endpoints 中，由调用方报告
+		result.SuccessCount = len(urls)
+		return result
+	}
+
+	result.Error = errors.Join(errs...)
+	result.FailedCount = len(urls)
+	result.FailedURLs = urls
+	// 所有接口都确认URL不属于该host时，不再重试
+	if permanent {
+		result.Permanent = make(map[string]string, len(urls))
+		for _, u := range urls {
+			result.Permanent[u] = "url_not_in_host"
+		}
+	}
+	return result
+}
+
+// Limits IndexNow 每次最多提交10000条，请求体为JSON数组
+// 字节上限为保守值，避免过大的请求体被网关拒绝
+func (s *IndexNowSubmitter) Limits() BatchLimits {
+	return BatchLimits{MaxURLs: 10000, MaxBytes: 1 << 20, URLOverhead: 3}
+}
+
+// Name 返回提交器名称
+func (s *IndexNowSubmitter) Name() string {
+	return s.name
+}
@@ -0,0 +1,152 @@
+package submitter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/k12/submit-sitemap/internal/httpretry"
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// IndexNow key 校验失败的原因，可以用 errors.Is 判断
+var (
+	ErrIndexNowKeyFormat   = errors.New("IndexNow key 格式无效，应为8-128位字母、数字或 -")
+	ErrIndexNowKeyHost     = errors.New("key_location 不在配置的 host 下")
+	ErrIndexNowKeyMismatch = errors.New("key 文件内容与 api_key 不一致")
+)
+
+// indexNowKeyPattern IndexNow 协议规定的key格式
+var indexNowKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9-]{8,128}$`)
+
+// indexNowKeyFileLimit key 文件读取上限，正常文件只有一行key
+const indexNowKeyFileLimit = 4096
+
+// ValidateIndexNowKey 检查key格式
+func ValidateIndexNowKey(key string) error {
+	if !indexNowKeyPattern.MatchString(key) {
+		return fmt.Errorf("%w: %q", ErrIndexNowKeyFormat, key)
+	}
+	return nil
+}
+
+// VerifyIndexNowKey 检查IndexNow配置：key格式、key文件是否位于同一host、key文件内容是否与 api_key 一致
+// 返回所有发现的问题，全部通过时返回nil
+func VerifyIndexNowKey(ctx context.Context, config types.IndexNowConfig, timeout int) error {
+	var errs []error
+	if err := ValidateIndexNowKey(config.APIKey); err != nil {
+		errs = append(errs, err)
+	}
+
+	keyLocation := IndexNowKeyLocation(config)
+	u, err := url.Parse(keyLocation)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("key_location 不是有效的 http(s) 地址: %s", keyLocation))
+		return errors.Join(errs...)
+	}
+	if !strings.EqualFold(u.Hostname(), config.Host) {
+		errs = append(errs, fmt.Errorf("%w: %s 不属于 %s", ErrIndexNowKeyHost, keyLocation, config.Host))
+	}
+
+	if err := checkIndexNowKeyFile(ctx, keyLocation, config.APIKey, timeout); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkIndexNowKeyFile 下载key文件并与key比较
+func checkIndexNowKeyFile(ctx context.Context, keyLocation, key string, timeout int) error {
+	client := httpretry.NewClient(time.Duration(timeout) * time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", keyLocation, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("User-Agent", DefaultIndexNowUserAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("下载 key 文件失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("下载 key 文件失败，状态码: %d（确认 %s 可以公开访问）", resp.StatusCode, keyLocation)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, indexNowKeyFileLimit))
+	if err != nil {
+		return fmt.Errorf("读取 key 文件失败: %w", err)
+	}
+
+	content := strings.TrimSpace(strings.TrimPrefix(string(body), "\ufeff"))
+	if content != key {
+		return fmt.Errorf("%w: 文件内容为 %q", ErrIndexNowKeyMismatch, truncateKey(content))
+	}
+	return nil
+}
+
+// truncateKey 截断过长的文件内容，避免错误信息过长
+func truncateKey(s string) string {
+	if len(s) > 140 {
+		return s[:140] + "..."
+	}
+	return s
+}
+
+// GenerateIndexNowKey 生成32位十六进制随机key
+func GenerateIndexNowKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成 key 失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// WriteIndexNowKeyFile 将key写入文件，path 为目录时写入 <path>/<key>.txt，返回实际写入的路径
+// 文件内容只有key本身，上传到网站根目录后即可作为 key_location
+func WriteIndexNowKeyFile(path, key string) (string, error) {
+	if err := ValidateIndexNowKey(key); err != nil {
+		return "", err
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, key+".txt")
+	}
+
+	if err := os.WriteFile(path, []byte(key+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("写入 key 文件失败: %w", err)
+	}
+	return path, nil
+}
+
+// VerifySiteIndexNowKeys 检查站点中所有使用IndexNow协议的引擎（bing、indexnow、naver）的key配置
+// 返回值的键为引擎名，值为 VerifyIndexNowKey 的结果；站点未配置IndexNow引擎时返回空map
+func VerifySiteIndexNowKeys(ctx context.Context, site types.SiteConfig, timeout int) map[string]error {
+	results := make(map[string]error)
+	for _, name := range []string{"bing", "indexnow", "naver"} {
+		engine, ok := site.Engines[name]
+		if !ok || engine.Quota <= 0 {
+			continue
+		}
+
+		var config types.IndexNowConfig
+		if err := engine.Decode(&config); err != nil {
+			results[name] = fmt.Errorf("解析 %s 配置失败: %w", name, err)
+			continue
+		}
+		config.SetSiteDefaults(site)
+		results[name] = VerifyIndexNowKey(ctx, config, timeout)
+	}
+	return results
+}
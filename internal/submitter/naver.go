@@ -0,0 +1,38 @@
+package submitter
+
+import (
+	"fmt"
+
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// NaverSubmitter Naver提交器
+// Naver Search Advisor 通过 IndexNow 协议接收提交，默认接口为 searchadvisor.naver.com
+type NaverSubmitter struct {
+	*IndexNowSubmitter
+}
+
+func init() {
+	RegisterEngine("naver", func(config types.NaverConfig, timeout int) (Submitter, error) {
+		if err := validateIndexNowConfig(config); err != nil {
+			return nil, fmt.Errorf("naver: %w", err)
+		}
+		s, err := NewNaverSubmitter(config, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("naver: %w", err)
+		}
+		return s, nil
+	})
+}
+
+// NewNaverSubmitter 创建Naver提交器，配置 endpoints 时使用配置的接口（如本地测试地址）
+func NewNaverSubmitter(config types.NaverConfig, timeout int) (*NaverSubmitter, error) {
+	if len(config.Endpoints) == 0 {
+		config.Endpoints = []string{"naver"}
+	}
+	s, err := NewIndexNowSubmitter("Naver", config, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &NaverSubmitter{s}, nil
+}
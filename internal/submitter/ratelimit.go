@@ -0,0 +1,100 @@
+package submitter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// RateLimiter 平台限速器，同时限制每秒请求数和每分钟URL数（令牌桶）
+// 同一平台的所有批次共用一个限速器：URL数按批次计，请求数按实际发送的每个HTTP请求计
+type RateLimiter struct {
+	mu       sync.Mutex
+	requests *tokenBucket
+	urls     *tokenBucket
+	now      func() time.Time
+}
+
+// NewRateLimiter 根据配置创建限速器，未配置的项不限制
+func NewRateLimiter(limit types.RateLimit) *RateLimiter {
+	limiter := &RateLimiter{now: time.Now}
+	if limit.RequestsPerSecond > 0 {
+		limiter.requests = newTokenBucket(limit.RequestsPerSecond, max(limit.RequestsPerSecond, 1))
+	}
+	if limit.URLsPerMinute > 0 {
+		perMinute := float64(limit.URLsPerMinute)
+		limiter.urls = newTokenBucket(perMinute/60, perMinute)
+	}
+	return limiter
+}
+
+// WaitRequest 等待直到可以发送下一个HTTP请求，ctx 取消时返回错误
+// 通过 httpretry.WithRateLimit 在每次请求（含重试、逐条提交和令牌请求）发送前调用
+func (l *RateLimiter) WaitRequest(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	return l.wait(ctx, l.requests, 1)
+}
+
+// WaitURLs 等待直到可以提交 urls 条URL，ctx 取消时返回错误
+// 一个批次的URL数超过每分钟上限时允许透支，由后续批次等待补足
+func (l *RateLimiter) WaitURLs(ctx context.Context, urls int) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	return l.wait(ctx, l.urls, float64(urls))
+}
+
+// wait 从令牌桶预约 n 个令牌并等待，桶为 nil 时不限制
+func (l *RateLimiter) wait(ctx context.Context, bucket *tokenBucket, n float64) error {
+	if bucket == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	delay := bucket.reserve(l.now(), n)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tokenBucket 令牌桶，令牌可以为负表示已预约的透支
+type tokenBucket struct {
+	rate   float64 // 每秒补充的令牌数
+	burst  float64 // 桶容量
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket 创建装满令牌的桶
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst}
+}
+
+// reserve 预约 n 个令牌，返回需要等待的时间
+func (b *tokenBucket) reserve(now time.Time, n float64) time.Duration {
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,146 @@
+package submitter
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+// Engine 已注册的提交引擎
+type Engine struct {
+	Name string
+	// Decode 将站点配置中 engines.<name> 的专有字段解码为引擎自己的配置
+	Decode func(config types.EngineConfig) (any, error)
+	// New 使用解码后的配置创建提交器，配置不完整时返回错误
+	New func(config any, timeout int) (Submitter, error)
+	// SiteDefaults 用站点配置补全解码后的配置（如 IndexNow 的 host 默认为站点域名），可以为 nil
+	SiteDefaults func(config any, site types.SiteConfig) any
+}
+
+// siteDefaulter 可以用站点配置补全默认值的引擎配置
+type siteDefaulter interface {
+	SetSiteDefaults(site types.SiteConfig)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Engine)
+)
+
+// Register 注册提交引擎，通常在引擎所在文件的 init 中调用，重复注册同名引擎会 panic
+func Register(engine Engine) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if engine.Name == "" || engine.Decode == nil || engine.New == nil {
+		panic("submitter: 引擎注册信息不完整")
+	}
+	if _, ok := registry[engine.Name]; ok {
+		panic("submitter: 重复注册引擎 " + engine.Name)
+	}
+	registry[engine.Name] = engine
+}
+
+// RegisterEngine 以具体配置类型注册提交引擎，自动生成配置解码器；
+// 配置类型的指针实现了 SetSiteDefaults 时，按站点创建提交器前用它补全默认值
+func RegisterEngine[C any](name string, newSubmitter func(config C, timeout int) (Submitter, error)) {
+	Register(Engine{
+		Name: name,
+		Decode: func(config types.EngineConfig) (any, error) {
+			var c C
+			if err := config.Decode(&c); err != nil {
+				return nil, fmt.Errorf("解析 %s 配置失败: %w", name, err)
+			}
+			return c, nil
+		},
+		New: func(config any, timeout int) (Submitter, error) {
+			c, ok := config.(C)
+			if !ok {
+				return nil, fmt.Errorf("%s 配置类型错误: %T", name, config)
+			}
+			return newSubmitter(c, timeout)
+		},
+		SiteDefaults: func(config any, site types.SiteConfig) any {
+			c, ok := config.(C)
+			if !ok {
+				return config
+			}
+			if d, ok := any(&c).(siteDefaulter); ok {
+				d.SetSiteDefaults(site)
+			}
+			return c
+		},
+	})
+}
+
+// Lookup 查找已注册的引擎
+func Lookup(name string) (Engine, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	engine, ok := registry[name]
+	return engine, ok
+}
+
+// Engines 返回全部已注册的引擎名（按名称排序）
+func Engines() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New 按引擎名和配置创建提交器
+func New(name string, config types.EngineConfig, timeout int) (Submitter, error) {
+	engine, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("未知的提交引擎: %s（可用: %v）", name, Engines())
+	}
+
+	decoded, err := engine.Decode(config)
+	if err != nil {
+		return nil, err
+	}
+	return engine.New(decoded, timeout)
+}
+
+// NewSiteSubmitters 创建站点配置中所有配额大于0的引擎的提交器，键为引擎名
+// 引擎配置中未填写的字段按站点补全默认值，如 bing、indexnow、naver 的 host 默认为站点域名
+func NewSiteSubmitters(site types.SiteConfig, timeout int) (map[string]Submitter, error) {
+	submitters := make(map[string]Submitter)
+	for name, config := range site.Engines {
+		if config.Quota <= 0 {
+			continue
+		}
+		s, err := newSiteSubmitter(site, name, config, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("网站 %s: %w", site.Domain, err)
+		}
+		submitters[name] = s
+	}
+	return submitters, nil
+}
+
+// newSiteSubmitter 解码引擎配置并按站点补全默认值后创建提交器
+func newSiteSubmitter(site types.SiteConfig, name string, config types.EngineConfig, timeout int) (Submitter, error) {
+	engine, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("未知的提交引擎: %s（可用: %v）", name, Engines())
+	}
+
+	decoded, err := engine.Decode(config)
+	if err != nil {
+		return nil, err
+	}
+	if engine.SiteDefaults != nil {
+		decoded = engine.SiteDefaults(decoded, site)
+	}
+	return engine.New(decoded, timeout)
+}
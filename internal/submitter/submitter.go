@@ -1,35 +1,198 @@
 package submitter
 
-import "github.com/k12/submit-sitemap/pkg/types"
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/k12/submit-sitemap/internal/httpretry"
+	"github.com/k12/submit-sitemap/pkg/types"
+)
 
 // Submitter 提交器接口
 type Submitter interface {
 	Submit(urls []string) types.SubmitResult
+	// SubmitContext 与 Submit 相同，ctx 取消或超时时中止正在进行的请求
+	SubmitContext(ctx context.Context, urls []string) types.SubmitResult
+	// Limits 返回平台单次请求的限制，BatchSubmit 据此拆分批次
+	Limits() BatchLimits
 	Name() string
 }
 
+// BatchLimits 平台单次请求的限制，0表示不限制
+type BatchLimits struct {
+	MaxURLs     int // 每次请求最多的URL数
+	MaxBytes    int // 请求体最大字节数
+	URLOverhead int // 每条URL在请求体中除URL本身外额外占用的字节数（分隔符、引号、包装等）
+}
+
+// split 将URL拆分为满足限制的批次：每批不超过 batchSize 条，估算的请求体不超过 MaxBytes
+// 单条URL本身超过 MaxBytes 时单独成批，由平台返回错误
+func (l BatchLimits) split(urls []string, batchSize int) [][]string {
+	var batches [][]string
+	start, size := 0, 0
+	for i, u := range urls {
+		n := len(u) + l.URLOverhead
+		full := i-start >= batchSize || (l.MaxBytes > 0 && size+n > l.MaxBytes)
+		if full && i > start {
+			batches = append(batches, urls[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	if start < len(urls) {
+		batches = append(batches, urls[start:])
+	}
+	return batches
+}
+
 // BatchSubmit 批量提交URL
 // 将URLs按batchSize分批提交
 func BatchSubmit(submitter Submitter, urls []string, batchSize int) []types.SubmitResult {
+	results, _ := BatchSubmitContext(context.Background(), submitter, urls, BatchOptions{BatchSize: batchSize})
+	return results
+}
+
+// BatchHook 每批提交完成后调用，batch 为本批提交的URL，返回 false 或错误时不再提交后续批次
+// 错误表示回调自身失败（例如台账写入失败），由 BatchSubmitContext 返回给调用方
+type BatchHook func(batch []string, result types.SubmitResult) (bool, error)
+
+// ChainHooks 依次调用多个回调，每个回调都会执行，任一返回 false 或错误时停止后续批次
+func ChainHooks(hooks ...BatchHook) BatchHook {
+	return func(batch []string, result types.SubmitResult) (bool, error) {
+		next := true
+		var errs []error
+		for _, hook := range hooks {
+			if hook == nil {
+				continue
+			}
+			ok, err := hook(batch, result)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if !ok || err != nil {
+				next = false
+			}
+		}
+		return next, errors.Join(errs...)
+	}
+}
+
+// BatchOptions 批量提交选项
+type BatchOptions struct {
+	BatchSize   int          // 每批URL数，不超过平台的 MaxURLs；为0时使用平台上限，平台不限制时为100
+	Concurrency int          // 同时提交的批次数（对应 settings.concurrent），默认1
+	Limiter     *RateLimiter // 平台限速器，可以为 nil
+	OnBatch     BatchHook    // 每批完成后的回调（例如更新配额台账、重试队列），可以为 nil
+}
+
+// BatchSubmitContext 批量提交URL，最多 Concurrency 个批次同时进行；每批开始前按URL数限速，
+// 批次内的每个HTTP请求（含重试）发送前按请求数限速
+// OnBatch 依次调用（不会并发），返回 false 或错误，或平台返回的剩余配额为0时，不再开始新的批次；
+// OnBatch 返回的第一个错误作为第二个返回值，此时已返回的结果可能没有完整记入台账或重试队列。
+// ctx 取消后不再开始新的批次，返回已开始批次的结果（按批次顺序），调用方据此保存已成功提交的URL；
+// 因取消而中止的批次不会调用 OnBatch
+func BatchSubmitContext(ctx context.Context, submitter Submitter, urls []string, opts BatchOptions) ([]types.SubmitResult, error) {
+	// 每批数量取调用方要求和平台上限中较小的一个
+	limits := submitter.Limits()
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = limits.MaxURLs
+	}
+	if limits.MaxURLs > 0 && batchSize > limits.MaxURLs {
+		batchSize = limits.MaxURLs
+	}
 	if batchSize <= 0 {
 		batchSize = 100 // 默认每批100条
 	}
 
-	var results []types.SubmitResult
+	// 分批，请求体超过平台字节上限的批次继续拆分
+	batches := limits.split(urls, batchSize)
 
-	// 分批提交
-	for i := 0; i < len(urls); i += batchSize {
-		end := i + batchSize
-		if end > len(urls) {
-			end = len(urls)
+	concurrency := min(max(opts.Concurrency, 1), max(len(batches), 1))
+
+	// 每个HTTP请求（含重试）发送前都消耗一个请求令牌，URL令牌按批次消耗
+	submitCtx := ctx
+	if opts.Limiter != nil {
+		submitCtx = httpretry.WithRateLimit(ctx, opts.Limiter.WaitRequest)
+	}
+
+	var (
+		mu      sync.Mutex
+		next    int
+		stopped bool
+		hookErr error
+		wg      sync.WaitGroup
+	)
+	results := make([]types.SubmitResult, len(batches))
+	started := make([]bool, len(batches))
+
+	// take 取出下一个待提交的批次，已停止或没有剩余批次时返回 -1
+	take := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped || next >= len(batches) || ctx.Err() != nil {
+			return -1
 		}
+		next++
+		return next - 1
+	}
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := take()
+				if i < 0 {
+					return
+				}
+				if err := opts.Limiter.WaitURLs(ctx, len(batches[i])); err != nil {
+					return
+				}
 
-		batch := urls[i:end]
-		result := submitter.Submit(batch)
-		results = append(results, result)
+				mu.Lock()
+				if stopped {
+					mu.Unlock()
+					return
+				}
+				started[i] = true
+				mu.Unlock()
+
+				result := submitter.SubmitContext(submitCtx, batches[i])
+
+				mu.Lock()
+				results[i] = result
+				if result.Error != nil && ctx.Err() != nil {
+					// 因取消而失败的批次不计入配额和重试队列
+					stopped = true
+				} else {
+					if opts.OnBatch != nil {
+						next, err := opts.OnBatch(batches[i], result)
+						if err != nil && hookErr == nil {
+							hookErr = err
+						}
+						if !next || err != nil {
+							stopped = true
+						}
+					}
+					if result.RemainKnown && result.Remain <= 0 {
+						stopped = true
+					}
+				}
+				mu.Unlock()
+			}
+		}()
 	}
+	wg.Wait()
 
-	return results
+	var done []types.SubmitResult
+	for i, ok := range started {
+		if ok {
+			done = append(done, results[i])
+		}
+	}
+	return done, hookErr
 }
 
 // MergeResults 合并多个提交结果
@@ -47,10 +210,23 @@ func MergeResults(results []types.SubmitResult) types.SubmitResult {
 		merged.SuccessCount += r.SuccessCount
 		merged.FailedCount += r.FailedCount
 		merged.FailedURLs = append(merged.FailedURLs, r.FailedURLs...)
+		merged.Attempts += r.Attempts
+		merged.Endpoints = append(merged.Endpoints, r.Endpoints...)
+		for u, reason := range r.Permanent {
+			if merged.Permanent == nil {
+				merged.Permanent = make(map[string]string)
+			}
+			merged.Permanent[u] = reason
+		}
 
 		if r.Error != nil && merged.Error == nil {
 			merged.Error = r.Error
 		}
+
+		// 剩余配额以最后一次返回的为准
+		if r.RemainKnown {
+			merged.Remain, merged.RemainKnown = r.Remain, true
+		}
 	}
 
 	return merged
@@ -0,0 +1,287 @@
+package submitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/k12/submit-sitemap/internal/httpretry"
+	"github.com/k12/submit-sitemap/pkg/types"
+)
+
+const defaultYandexEndpoint = "https://api.webmaster.yandex.net/v4"
+
+// YandexSubmitter Yandex提交器 (使用Webmaster recrawl API)
+// recrawl 接口每次只接受一个URL，提交前先查询当天剩余配额
+type YandexSubmitter struct {
+	client   *http.Client
+	endpoint string
+	token    string
+	hostID   string
+
+	mu     sync.Mutex
+	userID string // 未配置时首次提交通过 /user 接口获取
+}
+
+// yandexErrorResponse Webmaster API错误响应
+type yandexErrorResponse struct {
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// yandexQuota recrawl 配额
+type yandexQuota struct {
+	DailyQuota     int `json:"daily_quota"`
+	QuotaRemainder int `json:"quota_remainder"`
+}
+
+func init() {
+	RegisterEngine("yandex", func(config types.YandexConfig, timeout int) (Submitter, error) {
+		if config.Token == "" || config.HostID == "" {
+			return nil, fmt.Errorf("yandex: token 和 host_id 不能为空")
+		}
+		return NewYandexSubmitter(config, timeout), nil
+	})
+}
+
+// NewYandexSubmitter 创建Yandex提交器
+func NewYandexSubmitter(config types.YandexConfig, timeout int) *YandexSubmitter {
+	endpoint := strings.TrimRight(config.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = defaultYandexEndpoint
+	}
+
+	return &YandexSubmitter{
+		client:   httpretry.NewClient(time.Duration(timeout) * time.Second),
+		endpoint: endpoint,
+		token:    config.Token,
+		hostID:   config.HostID,
+		userID:   config.UserID,
+	}
+}
+
+// Submit 提交URL到Yandex
+func (y *YandexSubmitter) Submit(urls []string) types.SubmitResult {
+	return y.SubmitContext(context.Background(), urls)
+}
+
+// SubmitContext 逐条提交URL到Yandex，ctx 取消时中止正在进行的请求
+// 配额用完后剩余URL记为失败，等待下次运行
+func (y *YandexSubmitter) SubmitContext(ctx context.Context, urls []string) (result types.SubmitResult) {
+	result = types.SubmitResult{
+		Platform:   "Yandex",
+		TotalCount: len(urls),
+	}
+
+	if len(urls) == 0 {
+		return result
+	}
+
+	// 记录实际请求次数（含重试和逐条提交）
+	ctx, counter := httpretry.WithCounter(ctx)
+	defer func() { result.Attempts = counter.Attempts() }()
+
+	fail := func(i int, err error) types.SubmitResult {
+		result.Error = err
+		result.FailedURLs = append(result.FailedURLs, urls[i:]...)
+		result.FailedCount = len(result.FailedURLs)
+		return result
+	}
+
+	userID, err := y.user(ctx)
+	if err != nil {
+		return fail(0, err)
+	}
+
+	quota, err := y.quota(ctx, userID)
+	if err != nil {
+		return fail(0, err)
+	}
+	result.Remain, result.RemainKnown = quota.QuotaRemainder, true
+
+	for i, u := range urls {
+		if result.Remain <= 0 {
+			return fail(i, types.ErrOverQuota)
+		}
+		if err := ctx.Err(); err != nil {
+			return fail(i, err)
+		}
+
+		statusCode, yandexErr, err := y.recrawl(ctx, userID, u)
+		if err != nil {
+			return fail(i, err)
+		}
+
+		switch {
+		case statusCode == http.StatusAccepted || statusCode == http.StatusOK:
+			result.SuccessCount++
+			result.Remain--
+		case statusCode == http.StatusConflict:
+			// URL_ALREADY_ADDED: 已在抓取队列中，视为成功且不消耗配额
+			result.SuccessCount++
+		case statusCode == http.StatusTooManyRequests || yandexErr.ErrorCode == "QUOTA_EXCEEDED":
+			result.Remain = 0
+			return fail(i, types.ErrOverQuota)
+		case statusCode == http.StatusBadRequest && yandexErr.ErrorCode == "INVALID_URL":
+			result.FailedURLs = append(result.FailedURLs, u)
+			result.FailedCount++
+			if result.Permanent == nil {
+				result.Permanent = make(map[string]string)
+			}
+			result.Permanent[u] = "invalid_url"
+		case statusCode == http.StatusForbidden || statusCode == http.StatusNotFound:
+			// 站点未验证或未收录，后续URL同样会失败
+			return fail(i, yandexError(statusCode, yandexErr))
+		default:
+			result.Error = yandexError(statusCode, yandexErr)
+			result.FailedURLs = append(result.FailedURLs, u)
+			result.FailedCount++
+		}
+	}
+
+	if result.FailedCount > 0 && result.Error == nil {
+		result.Error = fmt.Errorf("部分URL提交失败: %d", result.FailedCount)
+	}
+
+	return result
+}
+
+// user 返回用户ID，未配置时通过 /user 接口获取并缓存
+func (y *YandexSubmitter) user(ctx context.Context) (string, error) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+
+	if y.userID != "" {
+		return y.userID, nil
+	}
+
+	var resp struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := y.get(ctx, "/user", &resp); err != nil {
+		return "", fmt.Errorf("获取 user_id 失败: %w", err)
+	}
+	y.userID = strconv.FormatInt(resp.UserID, 10)
+	return y.userID, nil
+}
+
+// quota 查询当天recrawl配额
+func (y *YandexSubmitter) quota(ctx context.Context, userID string) (yandexQuota, error) {
+	var quota yandexQuota
+	if err := y.get(ctx, y.hostPath(userID)+"/recrawl/quota", &quota); err != nil {
+		return quota, fmt.Errorf("查询配额失败: %w", err)
+	}
+	return quota, nil
+}
+
+// recrawl 将单个URL加入重新抓取队列
+// 配额用完时接口返回 429 QUOTA_EXCEEDED，重试也不会成功，因此关闭重试，由调用方按状态码处理
+func (y *YandexSubmitter) recrawl(ctx context.Context, userID, u string) (int, yandexErrorResponse, error) {
+	var yandexErr yandexErrorResponse
+
+	body, err := json.Marshal(map[string]string{"url": u})
+	if err != nil {
+		return 0, yandexErr, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := y.newRequest(httpretry.WithoutRetry(ctx), "POST", y.hostPath(userID)+"/recrawl/queue", bytes.NewReader(body))
+	if err != nil {
+		return 0, yandexErr, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := y.client.Do(req)
+	if err != nil {
+		return 0, yandexErr, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		if json.Unmarshal(respBody, &yandexErr) != nil || yandexErr.ErrorCode == "" {
+			yandexErr.ErrorMessage = strings.TrimSpace(string(respBody))
+		}
+	}
+	return resp.StatusCode, yandexErr, nil
+}
+
+// get 发送GET请求并解析JSON响应
+func (y *YandexSubmitter) get(ctx context.Context, path string, v any) error {
+	req, err := y.newRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := y.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var yandexErr yandexErrorResponse
+		if json.Unmarshal(respBody, &yandexErr) != nil || yandexErr.ErrorCode == "" {
+			yandexErr.ErrorMessage = strings.TrimSpace(string(respBody))
+		}
+		return yandexError(resp.StatusCode, yandexErr)
+	}
+
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("解析响应失败: %w (响应: %s)", err, string(respBody))
+	}
+	return nil
+}
+
+// newRequest 创建带OAuth认证的请求
+func (y *YandexSubmitter) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, y.endpoint+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", "OAuth "+y.token)
+	return req, nil
+}
+
+// hostPath 返回站点相关接口的路径前缀
+func (y *YandexSubmitter) hostPath(userID string) string {
+	return "/user/" + url.PathEscape(userID) + "/hosts/" + url.PathEscape(y.hostID)
+}
+
+// yandexError 根据响应构造错误
+func yandexError(statusCode int, yandexErr yandexErrorResponse) error {
+	msg := yandexErr.ErrorMessage
+	if yandexErr.ErrorCode != "" {
+		msg = yandexErr.ErrorCode + ": " + msg
+	}
+
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("HTTP错误 - 状态码: %d, 响应: %s（OAuth 令牌无效或已过期）", statusCode, msg)
+	case http.StatusForbidden, http.StatusNotFound:
+		return fmt.Errorf("HTTP错误 - 状态码: %d, 响应: %s（确认 host_id 正确且站点已在 Yandex Webmaster 中验证）", statusCode, msg)
+	}
+	return fmt.Errorf("HTTP错误 - 状态码: %d, 响应: %s", statusCode, msg)
+}
+
+// Limits recrawl 接口逐条提交，每批100条以便及时根据剩余配额停止
+func (y *YandexSubmitter) Limits() BatchLimits {
+	return BatchLimits{MaxURLs: 100}
+}
+
+// Name 返回提交器名称
+func (y *YandexSubmitter) Name() string {
+	return "Yandex"
+}
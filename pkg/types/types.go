@@ -1,11 +1,16 @@
 package types
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
 
 // Config 主配置结构（运行时使用，包含所有站点）
 type Config struct {
-	Sites    []SiteConfig    `yaml:"sites"`
-	Settings GlobalSettings  `yaml:"settings"`
+	Sites    []SiteConfig   `yaml:"sites"`
+	Settings GlobalSettings `yaml:"settings"`
 }
 
 // SiteConfigFile 单个站点配置文件结构（用于读取单个配置文件）
@@ -16,11 +21,87 @@ type SiteConfigFile struct {
 
 // SiteConfig 单个网站配置
 type SiteConfig struct {
-	Name       string            `yaml:"name"`
-	Domain     string            `yaml:"domain"`
-	SitemapURL string            `yaml:"sitemap_url"`
-	Quotas     QuotaConfig       `yaml:"quotas"`
-	API        APIConfig         `yaml:"api"`
+	Name             string         `yaml:"name"`
+	Domain           string         `yaml:"domain"`
+	SitemapURL       string         `yaml:"sitemap_url"`
+	Sources          []SourceConfig `yaml:"sources"`           // 多个URL来源，配置后与 sitemap_url 一起合并
+	DiscoverSitemaps bool           `yaml:"discover_sitemaps"` // 从 robots.txt 的 Sitemap 指令自动发现sitemap
+	SubmitAlternates bool           `yaml:"submit_alternates"` // 同时提交hreflang多语言版本URL
+	Quotas           QuotaConfig    `yaml:"quotas"`
+	API              APIConfig      `yaml:"api"`
+	// Engines 按引擎名配置提交平台，每个引擎的 quota 为每日配额，其余字段由引擎自行解析
+	// 旧的 quotas/api 配置会映射为 baidu、bing、google 引擎
+	Engines map[string]EngineConfig `yaml:"engines"`
+}
+
+// ForEngine 返回引擎实际使用的站点配置：引擎配置了 sources 时以其替换站点的URL来源
+func (s SiteConfig) ForEngine(name string) SiteConfig {
+	engine, ok := s.Engines[name]
+	if !ok || len(engine.Sources) == 0 {
+		return s
+	}
+	s.SitemapURL = ""
+	s.DiscoverSitemaps = false
+	s.Sources = engine.Sources
+	return s
+}
+
+// EngineConfig 单个提交引擎的配置
+// quota、sources 为所有引擎通用的字段，其余字段保留原始YAML，由注册的引擎解码为自己的配置结构
+type EngineConfig struct {
+	Quota   int
+	Sources []SourceConfig // 引擎专用的URL来源，配置后该引擎不使用站点的 sitemap_url、sources 和自动发现
+	Raw     yaml.Node
+}
+
+// UnmarshalYAML 解析通用字段并保留原始节点
+func (e *EngineConfig) UnmarshalYAML(node *yaml.Node) error {
+	var common struct {
+		Quota   int            `yaml:"quota"`
+		Sources []SourceConfig `yaml:"sources"`
+	}
+	if err := node.Decode(&common); err != nil {
+		return err
+	}
+	e.Quota = common.Quota
+	e.Sources = common.Sources
+	e.Raw = *node
+	return nil
+}
+
+// Decode 将引擎专有字段解码到 v
+func (e EngineConfig) Decode(v any) error {
+	if e.Raw.Kind == 0 {
+		return nil
+	}
+	return e.Raw.Decode(v)
+}
+
+// NewEngineConfig 由配额和引擎配置结构构造引擎配置，用于兼容旧的 quotas/api 写法
+func NewEngineConfig(quota int, config any) (EngineConfig, error) {
+	var node yaml.Node
+	if err := node.Encode(config); err != nil {
+		return EngineConfig{}, err
+	}
+	return EngineConfig{Quota: quota, Raw: node}, nil
+}
+
+// SourceConfig URL来源配置
+// url 与 file 二选一，内容格式（sitemap、纯文本、RSS、Atom）自动识别
+type SourceConfig struct {
+	URL     string   `yaml:"url"`     // 远程sitemap或feed地址
+	File    string   `yaml:"file"`    // 本地文件路径，相对路径基于配置文件所在目录
+	Include []string `yaml:"include"` // 只保留匹配任一正则的URL
+	Exclude []string `yaml:"exclude"` // 排除匹配任一正则的URL
+	Weight  int      `yaml:"weight"`  // 权重，越大越优先提交
+}
+
+// Location 返回来源地址
+func (s SourceConfig) Location() string {
+	if s.File != "" {
+		return s.File
+	}
+	return s.URL
 }
 
 // QuotaConfig 每日提交配额
@@ -28,6 +109,8 @@ type QuotaConfig struct {
 	Baidu  int `yaml:"baidu"`
 	Bing   int `yaml:"bing"`
 	Google int `yaml:"google"`
+	Yandex int `yaml:"yandex"`
+	Naver  int `yaml:"naver"`
 }
 
 // APIConfig API配置
@@ -35,56 +118,122 @@ type APIConfig struct {
 	Baidu  BaiduConfig  `yaml:"baidu"`
 	Bing   BingConfig   `yaml:"bing"`
 	Google GoogleConfig `yaml:"google"`
+	Yandex YandexConfig `yaml:"yandex"`
+	Naver  NaverConfig  `yaml:"naver"`
 }
 
 // BaiduConfig 百度API配置
 type BaiduConfig struct {
 	Token string `yaml:"token"`
 	Site  string `yaml:"site"`
+	// Mode 推送方式: urls（默认，普通收录）、amp、mip
+	// 快速收录、更新、删除分别使用 baidu_daily、baidu_update、baidu_del 引擎
+	Mode string `yaml:"mode"`
+	// DailyQuota 快速收录每日配额，大于0时另外启用 baidu_daily 引擎，配额和历史记录单独计算
+	// 同一次运行中快速收录先选取优先级最高的URL，普通收录跳过这些URL（见 history.Manager.SelectBaiduSplit）
+	DailyQuota int `yaml:"daily_quota"`
 }
 
-// BingConfig Bing API配置 (IndexNow)
-type BingConfig struct {
+// BingConfig Bing API配置，Bing 通过 IndexNow 协议提交
+type BingConfig = IndexNowConfig
+
+// IndexNowConfig IndexNow协议配置
+type IndexNowConfig struct {
 	APIKey      string `yaml:"api_key"`
 	Host        string `yaml:"host"`
-	KeyLocation string `yaml:"key_location"`
+	KeyLocation string `yaml:"key_location"` // 默认 https://<host>/<api_key>.txt
+	// Endpoints IndexNow 接口列表，可填名称（indexnow, bing, yandex, seznam, naver）或完整地址
+	// 默认只提交到 api.indexnow.org
+	Endpoints []string `yaml:"endpoints"`
+	UserAgent string   `yaml:"user_agent"` // 默认 Submit-Sitemap-Bot/1.0
+}
+
+// SetSiteDefaults 用站点配置补全默认值：未配置 host 时使用站点域名
+func (c *IndexNowConfig) SetSiteDefaults(site SiteConfig) {
+	if c.Host == "" {
+		c.Host = site.Domain
+	}
 }
 
-// GoogleConfig Google/IndexNow API配置
+// GoogleConfig Google Indexing API配置
 type GoogleConfig struct {
-	APIKey      string `yaml:"api_key"`
-	Host        string `yaml:"host"`
-	KeyLocation string `yaml:"key_location"`
+	CredentialsFile string `yaml:"credentials_file"` // 服务账号JSON密钥文件路径
+	Endpoint        string `yaml:"endpoint"`         // 批量接口地址，默认 https://indexing.googleapis.com/batch
+	TokenURL        string `yaml:"token_url"`        // OAuth2令牌地址，默认取密钥文件中的 token_uri
+}
+
+// YandexConfig Yandex Webmaster 重新抓取（recrawl）API配置
+type YandexConfig struct {
+	Token    string `yaml:"token"`    // OAuth令牌
+	UserID   string `yaml:"user_id"`  // 可选，默认通过 /user 接口获取
+	HostID   string `yaml:"host_id"`  // 站点ID，如 https:example.com:443
+	Endpoint string `yaml:"endpoint"` // API地址，默认 https://api.webmaster.yandex.net/v4
 }
 
+// NaverConfig Naver Search Advisor 配置，Naver 通过 IndexNow 协议接收提交
+// 未配置 endpoints 时提交到 searchadvisor.naver.com
+type NaverConfig = IndexNowConfig
+
 // GlobalSettings 全局设置
 type GlobalSettings struct {
-	SitemapCacheHours int    `yaml:"sitemap_cache_hours"`
-	Timeout           int    `yaml:"timeout"`
-	Concurrent        int    `yaml:"concurrent"`
-	LogLevel          string `yaml:"log_level"`
+	SitemapCacheHours int                  `yaml:"sitemap_cache_hours"`
+	MaxSitemapDepth   int                  `yaml:"max_sitemap_depth"`
+	Timeout           int                  `yaml:"timeout"`
+	Concurrent        int                  `yaml:"concurrent"`
+	LogLevel          string               `yaml:"log_level"`
+	QuotaTimezones    map[string]string    `yaml:"quota_timezones"` // 各平台每日配额重置的时区，默认百度 Asia/Shanghai，其余为本地时区
+	Retry             RetrySettings        `yaml:"retry"`
+	RateLimits        map[string]RateLimit `yaml:"rate_limits"` // 各平台限速，键为平台名（baidu、bing、google）
+}
+
+// RateLimit 平台限速设置，0表示不限制
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"` // 每秒最多HTTP请求数（含重试和逐条提交的请求）
+	URLsPerMinute     int     `yaml:"urls_per_minute"`     // 每分钟最多提交的URL数
+}
+
+// RetrySettings 失败URL重试队列设置
+type RetrySettings struct {
+	MaxAttempts      int `yaml:"max_attempts"`       // 最多尝试次数，超过后移入死信列表
+	BaseDelayMinutes int `yaml:"base_delay_minutes"` // 首次重试的等待时间（分钟），之后每次翻倍
+	MaxDelayHours    int `yaml:"max_delay_hours"`    // 重试等待时间上限（小时）
 }
 
 // SubmitResult 提交结果
 type SubmitResult struct {
-	Platform    string
-	TotalCount  int
+	Platform     string
+	TotalCount   int
 	SuccessCount int
-	FailedCount int
-	FailedURLs  []string
-	Error       error
+	FailedCount  int
+	FailedURLs   []string
+	Permanent    map[string]string // FailedURLs 中重试也不会成功的URL及原因（如百度 not_valid）
+	Error        error
+	Remain       int              // 平台返回的当天剩余配额，仅在 RemainKnown 为 true 时有效
+	RemainKnown  bool             // 平台是否返回了剩余配额
+	Attempts     int              // 实际发送的HTTP请求次数（含重试）
+	Endpoints    []EndpointResult // 同一批URL提交到多个接口时，每个接口的结果
+}
+
+// ErrOverQuota 平台当天配额已用完，未提交的URL留到配额重置后再提交，不算作提交失败
+var ErrOverQuota = errors.New("over quota")
+
+// EndpointResult 单个接口的提交结果
+type EndpointResult struct {
+	Endpoint   string
+	StatusCode int   // 未收到响应时为0
+	Error      error // 成功时为nil
 }
 
 // SubmitStats 提交统计
 type SubmitStats struct {
-	Site         string
-	Platform     string
-	SubmitCount  int
-	SuccessCount int
-	FailedCount  int
-	TotalURLs    int
+	Site          string
+	Platform      string
+	SubmitCount   int
+	SuccessCount  int
+	FailedCount   int
+	TotalURLs     int
 	SubmittedURLs int
-	Timestamp    time.Time
+	Timestamp     time.Time
 }
 
 // SitemapURL sitemap中的URL信息
@@ -93,4 +242,57 @@ type SitemapURL struct {
 	LastMod    string
 	ChangeFreq string
 	Priority   string
+	Alternates []AlternateURL // xhtml:link rel="alternate" 多语言版本
+	Images     []string       // image:image 中的图片地址
+	Videos     []SitemapVideo // video:video 视频信息
+	News       *SitemapNews   // news:news 新闻信息
+	Weight     int            // 所属来源的权重
+}
+
+// lastModLayouts sitemap lastmod 可用的W3C日期格式
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// ParseLastMod 解析 lastmod，无法识别时返回 false
+func ParseLastMod(value string) (time.Time, bool) {
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// LastModTime 返回解析后的 lastmod
+func (u SitemapURL) LastModTime() (time.Time, bool) {
+	return ParseLastMod(u.LastMod)
+}
+
+// AlternateURL hreflang多语言版本URL
+type AlternateURL struct {
+	Hreflang string
+	Href     string
+}
+
+// SitemapVideo sitemap视频扩展信息
+type SitemapVideo struct {
+	Title           string
+	ThumbnailLoc    string
+	ContentLoc      string
+	PlayerLoc       string
+	PublicationDate string
+}
+
+// SitemapNews sitemap新闻扩展信息
+type SitemapNews struct {
+	PublicationName string
+	Language        string
+	PublicationDate string
+	Title           string
 }